@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/login"
+	"github.com/spf13/cobra"
+)
+
+var editOpts login.EditOptions
+
+// accountsCmd represents the accounts command
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "manages the configured accounts and their credentials",
+	Args:  cobra.ExactArgs(0),
+}
+
+// accountsListCmd represents the accounts list command
+var accountsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "lists the configured accounts",
+	Long: `Lists the configured accounts, their server addresses, their credential store and whether their password or
+refresh token is stored. Secrets are never printed.`,
+	Args: cobra.ExactArgs(0),
+	RunE: login.ListRunE(fs, &selection),
+}
+
+// accountsEditCmd represents the accounts edit command
+var accountsEditCmd = &cobra.Command{
+	Use:   "edit <username|password> [value]",
+	Short: "changes a single stored credential",
+	Long: `Changes the stored username or password of an account, keeping the other credentials. The new value is
+prompted for in a terminal, otherwise read from the first line of stdin. Like login, the changed credentials are
+checked against the servers before they are stored.`,
+	Args:      cobra.RangeArgs(1, 2),
+	ValidArgs: []string{"username", "password"},
+	RunE:      login.EditRunE(fs, &selection, &editOpts),
+}
+
+func init() {
+	accountsEditCmd.Flags().BoolVar(&editOpts.NoVerify, "no-verify", false, "store the credential without connecting to the servers")
+
+	accountsCmd.AddCommand(accountsListCmd)
+	accountsCmd.AddCommand(accountsEditCmd)
+
+	rootCmd.AddCommand(accountsCmd)
+}
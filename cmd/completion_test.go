@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteAddressesOfAccount(t *testing.T) {
+	workDirectory := t.TempDir()
+	configPath := filepath.Join(workDirectory, "config.yaml")
+
+	files := map[string]string{
+		configPath:             "accounts:\n  - name: personal\n  - name: work\n",
+		"personal/inbox/Hello": "---\nFrom: Jim <jim@personal.com>\n---\n\nHello\n",
+		"work/inbox/Hello":     "---\nFrom: Jane Doe <jane@work.com>\n---\n\nHello\n",
+		"work/sent/Bye":        "---\nTo: john@work.com\n---\n\nBye\n",
+		filepath.Join("personal", state.Filename): `{"mailboxes":{"INBOX":{"directory":"inbox","uidValidity":1}}}`,
+		filepath.Join("work", state.Filename):     `{"mailboxes":{"INBOX":{"directory":"inbox","uidValidity":1}}}`,
+	}
+
+	for filePath, content := range files {
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(workDirectory, filePath)
+		}
+
+		err := fs.MkdirAll(filepath.Dir(filePath), 0o755)
+		assert.NoError(t, err)
+
+		err = fs.WriteFile(filePath, []byte(content), 0o600)
+		assert.NoError(t, err)
+	}
+
+	out := bytes.Buffer{}
+
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{
+		"__complete", "new", "--config", configPath, "-d", workDirectory, "--account", "work", "--to", "",
+	})
+
+	err := rootCmd.Execute()
+	assert.NoError(t, err)
+
+	candidates := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	assert.Equal(t, []string{"jane@work.com\tJane Doe", "john@work.com", ":4"}, candidates)
+}
@@ -0,0 +1,90 @@
+package draft
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/convert"
+	"github.com/deifyed/fsmail/pkg/drafts"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// ReplyRunE writes a draft answering the message file given as argument into the outbox
+func ReplyRunE(log logger, fs *afero.Afero, selection *accounts.Selection, opts *Options) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		original, err := readOriginal(fs, args[0])
+		if err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+
+		draft, err := drafts.Reply(original, selection.Current.From, opts.All)
+		if err != nil {
+			return fmt.Errorf("creating reply: %w", err)
+		}
+
+		return writeDraft(log, fs, selection.Current.Directory, draft, nil, opts.Edit)
+	}
+}
+
+// ForwardRunE writes a draft forwarding the message file given as argument, including its attachments, into the outbox
+func ForwardRunE(log logger, fs *afero.Afero, selection *accounts.Selection, opts *Options) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		original, err := readOriginal(fs, args[0])
+		if err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+
+		draft, err := drafts.Forward(original, selection.Current.From)
+		if err != nil {
+			return fmt.Errorf("creating forward: %w", err)
+		}
+
+		return writeDraft(log, fs, selection.Current.Directory, draft, original.Attachments, opts.Edit)
+	}
+}
+
+func readOriginal(fs *afero.Afero, messagePath string) (fsconv.Message, error) {
+	absoluteMessagePath, err := filepath.Abs(messagePath)
+	if err != nil {
+		return fsconv.Message{}, fmt.Errorf("acquiring absolute path: %w", err)
+	}
+
+	return fsconv.ReadMessage(fs, path.Dir(absoluteMessagePath), path.Base(absoluteMessagePath))
+}
+
+func writeDraft(log logger, fs *afero.Afero, targetDir string, draft convert.Message, attachments []fsconv.Attachment, edit bool) error {
+	absoluteWorkDirectory, err := filepath.Abs(targetDir)
+	if err != nil {
+		return fmt.Errorf("acquiring absolute target dir: %w", err)
+	}
+
+	absoluteOutboxDirectory := path.Join(absoluteWorkDirectory, outboxDirectoryName)
+
+	filename, err := drafts.Write(fs, absoluteOutboxDirectory, draft, attachments)
+	if err != nil {
+		return fmt.Errorf("writing draft: %w", err)
+	}
+
+	absoluteDraftPath := path.Join(absoluteOutboxDirectory, filename)
+
+	if draft.Draft {
+		log.Infof("Wrote draft to %s. Remove the Draft header to send it", absoluteDraftPath)
+	} else {
+		log.Infof("Wrote message to %s", absoluteDraftPath)
+	}
+
+	if !edit {
+		return nil
+	}
+
+	err = openEditor(log, absoluteDraftPath)
+	if err != nil {
+		return fmt.Errorf("editing draft: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package draft
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// CompleteAddresses offers the addresses found in the synced mailbox directories and the sent directory as completions
+// for address flags. Values can be comma separated lists, in which case the last address is completed. workDirectory
+// resolves the work directory of the account chosen by the flags of cmd, as the hooks selecting it do not run while
+// completing
+func CompleteAddresses(fs *afero.Afero, workDirectory func(cmd *cobra.Command) (string, error)) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		targetDir, err := workDirectory(cmd)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		absoluteWorkDirectory, err := filepath.Abs(targetDir)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		known := collectAddresses(fs, absoluteWorkDirectory)
+
+		completed := ""
+		partial := toComplete
+
+		if index := strings.LastIndex(toComplete, ","); index != -1 {
+			completed = toComplete[:index+1]
+			partial = strings.TrimSpace(toComplete[index+1:])
+		}
+
+		candidates := make([]string, 0)
+
+		for _, address := range sortedKeys(known) {
+			if !strings.HasPrefix(address, strings.ToLower(partial)) {
+				continue
+			}
+
+			candidate := completed + address
+			if name := known[address]; name != "" {
+				candidate += "\t" + name
+			}
+
+			candidates = append(candidates, candidate)
+		}
+
+		return candidates, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// collectAddresses maps the addresses found in the messages of the synced mailboxes and the sent messages to their
+// display names. Messages that can not be read or parsed are skipped, as completion should never fail
+func collectAddresses(fs *afero.Afero, absoluteWorkDirectory string) map[string]string {
+	result := make(map[string]string)
+
+	// A sync state that can not be read leaves the sent messages to complete from
+	found, _ := sync.Addresses(fs, absoluteWorkDirectory)
+
+	sentDirectory := path.Join(absoluteWorkDirectory, sentDirectoryName)
+
+	files, _ := fs.ReadDir(sentDirectory)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		list, err := fsconv.ReadAddresses(fs, path.Join(sentDirectory, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		found = append(found, list...)
+	}
+
+	for _, address := range found {
+		name, bare, err := addresses.Parse(address)
+		if err != nil {
+			continue
+		}
+
+		bare = strings.ToLower(bare)
+
+		if result[bare] == "" {
+			result[bare] = name
+		}
+	}
+
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
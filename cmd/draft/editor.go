@@ -0,0 +1,37 @@
+package draft
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// openEditor opens a file in the editor configured by VISUAL or EDITOR and waits for it to close
+func openEditor(log logger, filePath string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	// Editors are often configured with arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+
+	log.Debugf("Opening %s with %s", filePath, editor)
+
+	command := exec.Command(fields[0], append(fields[1:], filePath)...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	err := command.Run()
+	if err != nil {
+		return fmt.Errorf("running %s: %w", editor, err)
+	}
+
+	return nil
+}
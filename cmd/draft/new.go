@@ -0,0 +1,179 @@
+package draft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/convert"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var errMissingRecipients = errors.New("missing recipients")
+
+// NewRunE writes a new message composed from flags, or from prompts when stdin is a terminal, into the outbox
+func NewRunE(log logger, fs *afero.Afero, selection *accounts.Selection, opts *NewOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if opts.From == "" {
+			opts.From = selection.Current.From
+		}
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd()))
+
+		if interactive {
+			err := promptForMissing(cmd.InOrStdin(), cmd.OutOrStdout(), opts)
+			if err != nil {
+				return fmt.Errorf("prompting: %w", err)
+			}
+		} else if opts.Body == "" && !opts.Edit {
+			body, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("reading body from stdin: %w", err)
+			}
+
+			opts.Body = string(body)
+		}
+
+		msg, err := composeMessage(fs, *opts)
+		if err != nil {
+			return fmt.Errorf("composing message: %w", err)
+		}
+
+		return writeDraft(log, fs, selection.Current.Directory, msg, nil, opts.Edit)
+	}
+}
+
+// composeMessage turns the options into a message, and validates it the same way the outbox does when sending
+func composeMessage(fs *afero.Afero, opts NewOptions) (convert.Message, error) {
+	msg := convert.Message{
+		From:    opts.From,
+		Subject: opts.Subject,
+		Format:  opts.Format,
+		Body:    strings.TrimSpace(opts.Body),
+		Draft:   opts.Draft || opts.Edit,
+	}
+
+	if msg.From != "" {
+		_, _, err := addresses.Parse(msg.From)
+		if err != nil {
+			return convert.Message{}, fmt.Errorf("parsing from: %w", err)
+		}
+	}
+
+	var err error
+
+	for _, field := range []struct {
+		name   string
+		values []string
+		target *[]string
+	}{
+		{name: "to", values: opts.To, target: &msg.To},
+		{name: "cc", values: opts.Cc, target: &msg.Cc},
+		{name: "bcc", values: opts.Bcc, target: &msg.Bcc},
+	} {
+		*field.target, err = parseAddressFlag(field.values)
+		if err != nil {
+			return convert.Message{}, fmt.Errorf("parsing %s: %w", field.name, err)
+		}
+	}
+
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return convert.Message{}, errMissingRecipients
+	}
+
+	for _, attachment := range opts.Attachments {
+		absoluteAttachmentPath, err := filepath.Abs(attachment)
+		if err != nil {
+			return convert.Message{}, fmt.Errorf("acquiring absolute path of %s: %w", attachment, err)
+		}
+
+		exists, err := fs.Exists(absoluteAttachmentPath)
+		if err != nil {
+			return convert.Message{}, fmt.Errorf("checking attachment %s: %w", attachment, err)
+		}
+
+		if !exists {
+			return convert.Message{}, fmt.Errorf("attachment %s does not exist", attachment)
+		}
+
+		msg.Attachments = append(msg.Attachments, absoluteAttachmentPath)
+	}
+
+	// Parsing the rendered message catches anything the outbox would reject at send time
+	validated, err := convert.ToMessage(convert.ToReader(msg))
+	if err != nil {
+		return convert.Message{}, fmt.Errorf("validating: %w", err)
+	}
+
+	msg.Format = validated.Format
+
+	return msg, nil
+}
+
+// parseAddressFlag parses every occurrence of an address flag, each of which can contain a comma separated list
+func parseAddressFlag(values []string) ([]string, error) {
+	result := make([]string, 0)
+
+	for _, value := range values {
+		list, err := addresses.ParseList(value)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, list...)
+	}
+
+	return result, nil
+}
+
+func promptForMissing(in io.Reader, out io.Writer, opts *NewOptions) error {
+	p := newPrompter(in, out)
+
+	if opts.From == "" {
+		from, err := p.promptRequired("From: ")
+		if err != nil {
+			return fmt.Errorf("prompting for sender: %w", err)
+		}
+
+		opts.From = from
+	}
+
+	if len(opts.To) == 0 {
+		to, err := p.promptRequired("To: ")
+		if err != nil {
+			return fmt.Errorf("prompting for recipients: %w", err)
+		}
+
+		opts.To = []string{to}
+	}
+
+	if opts.Subject == "" {
+		subject, err := p.prompt("Subject: ")
+		if err != nil {
+			return fmt.Errorf("prompting for subject: %w", err)
+		}
+
+		opts.Subject = subject
+	}
+
+	if opts.Body == "" && !opts.Edit {
+		fmt.Fprintln(out, "Body, end with Ctrl-D:")
+
+		body, err := io.ReadAll(p.reader)
+		if err != nil {
+			return fmt.Errorf("reading body: %w", err)
+		}
+
+		opts.Body = string(bytes.TrimSpace(body))
+	}
+
+	return nil
+}
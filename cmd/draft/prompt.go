@@ -0,0 +1,44 @@
+package draft
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type prompter struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) prompter {
+	return prompter{reader: bufio.NewReader(in), out: out}
+}
+
+// prompt asks for a single line of input. An empty answer is allowed
+func (p prompter) prompt(msg string) (string, error) {
+	fmt.Fprint(p.out, msg)
+
+	line, err := p.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// promptRequired asks for a single line of input until the answer is not empty
+func (p prompter) promptRequired(msg string) (string, error) {
+	for {
+		answer, err := p.prompt(msg)
+		if err != nil {
+			return "", err
+		}
+
+		if answer != "" {
+			return answer, nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package draft
+
+type logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+}
+
+// Options contains the flags shared by the commands creating drafts
+type Options struct {
+	// All addresses a reply to everyone involved in the original message
+	All bool
+	// Edit opens the draft in the user's editor once it is written
+	Edit bool
+}
+
+// NewOptions contains the flags of the command composing new messages
+type NewOptions struct {
+	Options
+	// From overrides the sender configured with the from key
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	Body        string
+	Format      string
+	Attachments []string
+	// Draft writes the message as a draft that is not sent until the Draft header is removed
+	Draft bool
+}
+
+const (
+	outboxDirectoryName = "outbox"
+	sentDirectoryName   = "sent"
+	defaultEditor       = "vi"
+)
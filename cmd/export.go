@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/spf13/cobra"
+)
+
+var exportOpts sync.ExportOptions
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "exports a folder as an mbox file",
+	Long: `Writes every message of a folder in the work directory to stdout as an mbox file. Messages with a stored
+raw message are exported as received, others are rendered from their message file. Flags are written as Status and
+X-Status headers.`,
+	Args: cobra.ExactArgs(0),
+	RunE: sync.ExportRunE(log, fs, &accountDirectory, &exportOpts),
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportOpts.Folder, "mbox", "", "folder to export, relative to the work directory")
+
+	err := exportCmd.MarkFlagRequired("mbox")
+	cobra.CheckErr(err)
+
+	rootCmd.AddCommand(exportCmd)
+}
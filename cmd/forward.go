@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/draft"
+	"github.com/spf13/cobra"
+)
+
+var forwardOpts draft.Options
+
+// forwardCmd represents the forward command
+var forwardCmd = &cobra.Command{
+	Use:   "forward <file>",
+	Short: "writes a draft forwarding a message into the outbox",
+	Long: `Writes a draft forwarding a message file and its attachments into the outbox directory.
+The draft is not sent until its Draft header is removed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: draft.ForwardRunE(log, fs, &selection, &forwardOpts),
+}
+
+func init() {
+	forwardCmd.Flags().BoolVarP(&forwardOpts.Edit, "edit", "e", false, "open the draft in $EDITOR")
+
+	rootCmd.AddCommand(forwardCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/spf13/cobra"
+)
+
+var importOpts sync.ImportOptions
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "imports messages from an mbox file",
+	Long: `Stores every message of an mbox file in a folder of the work directory, using the configured storage. The
+raw messages are kept, so exporting the folder again preserves every header and attachment. Imported messages stay
+local and are not uploaded to the server.`,
+	Args: cobra.ExactArgs(0),
+	RunE: sync.ImportRunE(log, fs, &accountDirectory, &importOpts),
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importOpts.Mbox, "mbox", "", "mbox file to import")
+	importCmd.Flags().StringVar(&importOpts.Into, "into", "", "folder to import into, relative to the work directory")
+
+	for _, flag := range []string{"mbox", "into"} {
+		err := importCmd.MarkFlagRequired(flag)
+		cobra.CheckErr(err)
+	}
+
+	rootCmd.AddCommand(importCmd)
+}
@@ -5,14 +5,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var loginOpts login.Options
+
 // loginCmd represents the login command
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "A brief description of your command",
 	Args:  cobra.ExactArgs(0),
-	RunE:  login.RunE(fs),
+	RunE:  login.RunE(fs, &selection, &loginOpts),
 }
 
 func init() {
+	loginCmd.Flags().StringVar(&loginOpts.Username, "username", "", "username, prompted for when missing")
+	loginCmd.Flags().BoolVar(&loginOpts.PasswordStdin, "password-stdin", false, "read the password from stdin")
+	loginCmd.Flags().BoolVar(&loginOpts.NoVerify, "no-verify", false, "store the credentials without connecting to the servers")
+
 	rootCmd.AddCommand(loginCmd)
 }
@@ -0,0 +1,178 @@
+package login
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// EditOptions contains the flags of the accounts edit command
+type EditOptions struct {
+	// NoVerify stores the changed credentials without connecting to the servers
+	NoVerify bool
+}
+
+// editableFields maps the fields accounts edit can change to their key in the credentials store. Server addresses are
+// configured, not stored
+var editableFields = map[string]string{
+	"username": credentials.UsernameKey,
+	"password": credentials.PasswordKey,
+}
+
+var (
+	errUnknownField     = errors.New("unknown field")
+	errPasswordArgument = errors.New("refusing to read the password from an argument, pass it on stdin")
+)
+
+// ListRunE prints the configured accounts, their server addresses and whether their secret is stored. Secrets are
+// never printed
+func ListRunE(fs *afero.Afero, selection *accounts.Selection) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "ACCOUNT\tIMAP SERVER\tSMTP SERVER\tSTORE\tSECRET")
+
+		for _, account := range selection.All {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+				account.DisplayName(),
+				account.IMAPServerAddress,
+				account.SMTPServerAddress,
+				account.CredentialStore,
+				secretStatus(fs, account),
+			)
+		}
+
+		return writer.Flush()
+	}
+}
+
+// EditRunE changes a single stored credential of the selected account, keeping the others
+func EditRunE(fs *afero.Afero, selection *accounts.Selection, opts *EditOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		account := selection.Current
+
+		key, ok := editableFields[args[0]]
+		if !ok {
+			return fmt.Errorf("%w %q, expected username or password", errUnknownField, args[0])
+		}
+
+		// Arguments end up in the shell history
+		if key == credentials.PasswordKey && len(args) > 1 {
+			return errPasswordArgument
+		}
+
+		if key == credentials.PasswordKey && account.OAuth2.Enabled() {
+			return fmt.Errorf("editing password: %w", errPasswordUnused)
+		}
+
+		if account.ReadOnlyCredentials() {
+			return fmt.Errorf("the credentials of account %s are managed outside of fsmail: %w",
+				account.DisplayName(), credentials.ErrReadOnly)
+		}
+
+		store, err := account.CredentialsStore(fs)
+		if err != nil {
+			return fmt.Errorf("opening credentials store: %w", err)
+		}
+
+		secrets, err := credentials.Read(store)
+		if err != nil {
+			return fmt.Errorf("reading credentials, log in first: %w", err)
+		}
+
+		value, err := readValue(cmd, args, key == credentials.PasswordKey)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", args[0], err)
+		}
+
+		switch key {
+		case credentials.UsernameKey:
+			secrets.Username = value
+		case credentials.PasswordKey:
+			secrets.Password = value
+		}
+
+		if !opts.NoVerify {
+			// Verifying might replace the refresh token, which is stored together with the changed credential
+			creds, err := account.EmailCredentials(secrets, func(refreshToken string) error {
+				secrets.RefreshToken = refreshToken
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("preparing credentials: %w", err)
+			}
+
+			err = verify(cmd, creds)
+			if err != nil {
+				return fmt.Errorf("not storing credentials: %w", err)
+			}
+		}
+
+		err = credentials.Write(store, secrets)
+		if err != nil {
+			return fmt.Errorf("storing credentials: %w", err)
+		}
+
+		successPrint(cmd.OutOrStdout(), "Credentials")
+
+		return nil
+	}
+}
+
+// secretStatus describes whether the password or refresh token of an account is stored, without revealing it
+func secretStatus(fs *afero.Afero, account accounts.Account) string {
+	// Running the command could ask for the passphrase of a password manager
+	if account.CredentialStore == accounts.StoreCommand {
+		return "command"
+	}
+
+	store, err := account.CredentialsStore(fs)
+	if err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+
+	secret, err := store.Get(credentials.CredentialsSecretName, secretKey(account))
+
+	switch {
+	case errors.Is(err, credentials.ErrNotFound):
+		return "missing"
+	case err != nil:
+		return fmt.Sprintf("unknown: %s", err)
+	case secret == "":
+		return "missing"
+	default:
+		return "present"
+	}
+}
+
+// readValue takes the new value from the arguments, a prompt or the first line of stdin
+func readValue(cmd *cobra.Command, args []string, hidden bool) (string, error) {
+	if len(args) > 1 {
+		return args[1], nil
+	}
+
+	p := newPrompter(cmd.InOrStdin(), cmd.OutOrStdout())
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return p.promptRequired("New "+args[0]+": ", hidden)
+	}
+
+	value, err := p.read(false)
+	if err != nil {
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+
+	if value == "" {
+		return "", errMissingValue
+	}
+
+	return value, nil
+}
@@ -1,25 +1,108 @@
 package login
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
-	"github.com/deifyed/fsmail/pkg/keyring"
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/oauth"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
-func RunE(fs *afero.Afero) func(*cobra.Command, []string) error {
+// Options contains the flags of the login command
+type Options struct {
+	// NoVerify stores the credentials without connecting to the servers, e.g. for setting up offline
+	NoVerify bool
+	// Username overrides the username of the account
+	Username string
+	// PasswordStdin reads the password from stdin instead of prompting for it
+	PasswordStdin bool
+}
+
+var errVerificationFailed = errors.New("verification failed")
+
+func RunE(fs *afero.Afero, selection *accounts.Selection, opts *Options) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		creds := promptForCredentials()
+		account := selection.Current
+
+		store, err := account.CredentialsStore(fs)
+		if err != nil {
+			return fmt.Errorf("opening credentials store: %w", err)
+		}
+
+		// Credentials managed elsewhere can only be checked
+		if account.ReadOnlyCredentials() {
+			if !opts.NoVerify {
+				creds, err := account.Credentials(fs)
+				if err != nil {
+					return fmt.Errorf("reading credentials: %w", err)
+				}
+
+				err = verify(cmd, creds)
+				if err != nil {
+					return err
+				}
+			}
 
-		keyringClient := keyring.Client{Prefix: generatePrefix(creds.Username)}
+			err = validateCredentials(store, secretKey(account))
+			if err != nil {
+				return fmt.Errorf("validating credentials: %w", err)
+			}
 
-		err := storeCredentials(keyringClient, creds)
+			successPrint(cmd.OutOrStdout(), "Credentials")
+
+			return nil
+		}
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd()))
+
+		creds, err := collectCredentials(cmd.InOrStdin(), cmd.OutOrStdout(), interactive, account, *opts)
+		if err != nil {
+			return fmt.Errorf("collecting credentials: %w", err)
+		}
+
+		verifiedCreds := email.Credentials{
+			IMAPServerAddress: creds.IMAPServerAddress,
+			SMTPServerAddress: creds.SMTPServerAddress,
+			Username:          creds.Username,
+			Password:          creds.Password,
+		}
+
+		if account.OAuth2.Enabled() {
+			oauthConfig, err := account.OAuth2.Resolve()
+			if err != nil {
+				return fmt.Errorf("preparing OAuth 2.0: %w", err)
+			}
+
+			token, err := oauth.Authorize(cmd.Context(), oauthConfig, cmd.OutOrStdout())
+			if err != nil {
+				return fmt.Errorf("authorizing: %w", err)
+			}
+
+			creds.RefreshToken = token.RefreshToken
+			verifiedCreds.Mechanism = oauthConfig.Mechanism
+			verifiedCreds.Token = func() (string, error) { return token.AccessToken, nil }
+		}
+
+		// Only credentials that work are worth keeping
+		if !opts.NoVerify {
+			err = verify(cmd, verifiedCreds)
+			if err != nil {
+				return fmt.Errorf("not storing credentials: %w", err)
+			}
+		}
+
+		err = credentials.Write(store, creds)
 		if err != nil {
 			return fmt.Errorf("storing credentials: %w", err)
 		}
 
-		err = validateCredentials(keyringClient)
+		err = validateCredentials(store, secretKey(account))
 		if err != nil {
 			return fmt.Errorf("validating credentials: %w", err)
 		}
@@ -29,3 +112,35 @@ func RunE(fs *afero.Afero) func(*cobra.Command, []string) error {
 		return nil
 	}
 }
+
+// verify logs in to both servers, reporting the result of each
+func verify(cmd *cobra.Command, creds email.Credentials) error {
+	checks := []struct {
+		name string
+		fn   func(email.Credentials) error
+	}{
+		{name: "IMAP", fn: email.VerifyIMAP},
+		{name: "SMTP", fn: email.VerifySMTP},
+	}
+
+	failed := 0
+
+	for _, check := range checks {
+		err := check.fn(creds)
+		if err != nil {
+			failurePrint(cmd.OutOrStdout(), check.name, err)
+
+			failed++
+
+			continue
+		}
+
+		successPrint(cmd.OutOrStdout(), check.name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d servers: %w", failed, len(checks), errVerificationFailed)
+	}
+
+	return nil
+}
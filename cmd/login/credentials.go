@@ -1,15 +1,31 @@
 package login
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 
+	"github.com/deifyed/fsmail/pkg/accounts"
 	"github.com/deifyed/fsmail/pkg/credentials"
 )
 
-func validateCredentials(store credentials.CredentialsStore) error {
+var (
+	errMissingValue   = errors.New("missing value")
+	errPasswordUnused = errors.New("accounts using OAuth 2.0 have no password")
+)
+
+// validateCredentials checks that the stored credentials can be read back. secretKey identifies the password or the
+// refresh token
+func validateCredentials(store credentials.CredentialsStore, secretKey string) error {
 	_, err := store.Get(credentials.CredentialsSecretName, credentials.SMTPServerAddressKey)
 	if err != nil {
-		return fmt.Errorf("retrieving server address: %w", err)
+		return fmt.Errorf("retrieving SMTP server address: %w", err)
+	}
+
+	_, err = store.Get(credentials.CredentialsSecretName, credentials.IMAPServerAddressKey)
+	if err != nil {
+		return fmt.Errorf("retrieving IMAP server address: %w", err)
 	}
 
 	_, err = store.Get(credentials.CredentialsSecretName, credentials.UsernameKey)
@@ -17,35 +33,91 @@ func validateCredentials(store credentials.CredentialsStore) error {
 		return fmt.Errorf("retrieving username: %w", err)
 	}
 
-	_, err = store.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	_, err = store.Get(credentials.CredentialsSecretName, secretKey)
 	if err != nil {
-		return fmt.Errorf("retrieving password: %w", err)
+		return fmt.Errorf("retrieving %s: %w", secretKey, err)
 	}
 
 	return nil
 }
 
-func promptForCredentials() credentials.Credentials {
-	creds := credentials.Credentials{}
+// credentialField describes a value collectCredentials prompts for, and the flag providing it
+type credentialField struct {
+	label  string
+	flag   string
+	hidden bool
+	target *string
+}
+
+// collectCredentials gathers the credentials from the flags and the account settings, and prompts for the missing
+// ones when stdin is a terminal. The password is skipped for accounts authorized with OAuth 2.0
+func collectCredentials(in io.Reader, out io.Writer, interactive bool, account accounts.Account, opts Options) (credentials.Credentials, error) {
+	withPassword := !account.OAuth2.Enabled()
+
+	creds := credentials.Credentials{
+		SMTPServerAddress: account.SMTPServerAddress,
+		IMAPServerAddress: account.IMAPServerAddress,
+		Username:          opts.Username,
+	}
+
+	if creds.Username == "" {
+		creds.Username = account.Username
+	}
+
+	if opts.PasswordStdin {
+		if !withPassword {
+			return credentials.Credentials{}, fmt.Errorf("--password-stdin: %w", errPasswordUnused)
+		}
+
+		rawPassword, err := io.ReadAll(in)
+		if err != nil {
+			return credentials.Credentials{}, fmt.Errorf("reading password from stdin: %w", err)
+		}
 
-	creds.SMTPServerAddress = prompter("SMTP server address: ", false)
-	creds.IMAPServerAddress = prompter("IMAP server address: ", false)
-	creds.Username = prompter("Username: ", false)
-	creds.Password = prompter("Password: ", true)
+		creds.Password = strings.TrimRight(string(rawPassword), "\r\n")
+		if creds.Password == "" {
+			return credentials.Credentials{}, fmt.Errorf("reading password from stdin: %w", errMissingValue)
+		}
+	}
+
+	fields := []credentialField{
+		{label: "SMTP server address", flag: "--smtp-server-address", target: &creds.SMTPServerAddress},
+		{label: "IMAP server address", flag: "--imap-server-address", target: &creds.IMAPServerAddress},
+		{label: "Username", flag: "--username", target: &creds.Username},
+	}
+
+	if withPassword {
+		fields = append(fields, credentialField{label: "Password", flag: "--password-stdin", hidden: true, target: &creds.Password})
+	}
+
+	p := newPrompter(in, out)
+
+	for _, field := range fields {
+		if *field.target != "" {
+			continue
+		}
 
-	return creds
+		if !interactive {
+			return credentials.Credentials{}, fmt.Errorf("%s: %w, and stdin is not a terminal to prompt for it",
+				field.flag, errMissingValue)
+		}
+
+		answer, err := p.promptRequired(field.label+": ", field.hidden)
+		if err != nil {
+			return credentials.Credentials{}, fmt.Errorf("prompting for %s: %w", strings.ToLower(field.label), err)
+		}
+
+		*field.target = answer
+	}
+
+	return creds, nil
 }
 
-func storeCredentials(store credentials.CredentialsStore, creds credentials.Credentials) error {
-	err := store.Put(credentials.CredentialsSecretName, map[string]string{
-		credentials.SMTPServerAddressKey: creds.SMTPServerAddress,
-		credentials.IMAPServerAddressKey: creds.IMAPServerAddress,
-		credentials.UsernameKey:          creds.Username,
-		credentials.PasswordKey:          creds.Password,
-	})
-	if err != nil {
-		return fmt.Errorf("storing credentials: %w", err)
+// secretKey identifies the secret of an account in its store, the password or the refresh token
+func secretKey(account accounts.Account) string {
+	if account.OAuth2.Enabled() {
+		return credentials.RefreshTokenKey
 	}
 
-	return nil
+	return credentials.PasswordKey
 }
@@ -1,37 +1,64 @@
 package login
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"syscall"
+	"os"
+	"strings"
 
 	"github.com/logrusorgru/aurora"
 	"golang.org/x/term"
 )
 
-func prompter(msg string, hidden bool) string {
-	fmt.Print(msg)
+type prompter struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
 
-	var result string
+func newPrompter(in io.Reader, out io.Writer) prompter {
+	return prompter{reader: bufio.NewReader(in), out: out}
+}
 
-	if hidden {
-		rawResult, _ := term.ReadPassword(syscall.Stdin)
+// promptRequired asks for a single line of input until the answer is not empty. Hidden answers are read from the
+// terminal without echoing them
+func (p prompter) promptRequired(msg string, hidden bool) (string, error) {
+	for {
+		fmt.Fprint(p.out, msg)
 
-		result = string(rawResult)
-	} else {
-		_, err := fmt.Scanln(&result)
+		answer, err := p.read(hidden)
 		if err != nil {
-			panic(err.Error())
+			return "", err
 		}
+
+		if answer != "" {
+			return answer, nil
+		}
+	}
+}
+
+func (p prompter) read(hidden bool) (string, error) {
+	if hidden {
+		rawAnswer, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+		fmt.Fprintln(p.out)
+
+		return strings.TrimSpace(string(rawAnswer)), err
+	}
+
+	line, err := p.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
 	}
 
-	return result
+	return strings.TrimSpace(line), nil
 }
 
 func successPrint(out io.Writer, name string) {
 	fmt.Fprintf(out, "\n[%s] %s\n", name, aurora.Green("OK"))
 }
 
-func generatePrefix(username string) string {
-	return "fssmtp"
+func failurePrint(out io.Writer, name string, err error) {
+	fmt.Fprintf(out, "\n[%s] %s: %s\n", name, aurora.Red("FAILED"), err)
 }
@@ -0,0 +1,38 @@
+package login
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// LogoutRunE removes the stored credentials of the selected account
+func LogoutRunE(fs *afero.Afero, selection *accounts.Selection) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		account := selection.Current
+
+		store, err := account.CredentialsStore(fs)
+		if err != nil {
+			return fmt.Errorf("opening credentials store: %w", err)
+		}
+
+		err = store.Delete(credentials.CredentialsSecretName)
+		if errors.Is(err, credentials.ErrNotFound) {
+			fmt.Fprintf(cmd.OutOrStdout(), "No credentials stored for account %s\n", account.DisplayName())
+
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("removing credentials of account %s: %w", account.DisplayName(), err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed credentials of account %s\n", account.DisplayName())
+
+		return nil
+	}
+}
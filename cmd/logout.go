@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/login"
+	"github.com/spf13/cobra"
+)
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "removes the stored credentials of an account",
+	Args:  cobra.ExactArgs(0),
+	RunE:  login.LogoutRunE(fs, &selection),
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+}
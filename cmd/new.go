@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/draft"
+	"github.com/spf13/cobra"
+)
+
+var newOpts draft.NewOptions
+
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "writes a new message into the outbox",
+	Long: `Writes a new message into the outbox directory. Values missing from the flags are prompted for when running
+in a terminal. Otherwise the body is read from stdin.`,
+	Args: cobra.ExactArgs(0),
+	RunE: draft.NewRunE(log, fs, &selection, &newOpts),
+}
+
+func init() {
+	newCmd.Flags().StringVar(&newOpts.From, "from", "", "sender, defaults to the from key of the account")
+	newCmd.Flags().StringArrayVarP(&newOpts.To, "to", "t", nil, "recipients, repeatable or comma separated")
+	newCmd.Flags().StringArrayVar(&newOpts.Cc, "cc", nil, "carbon copy recipients, repeatable or comma separated")
+	newCmd.Flags().StringArrayVar(&newOpts.Bcc, "bcc", nil, "blind carbon copy recipients, repeatable or comma separated")
+	newCmd.Flags().StringVar(&newOpts.Subject, "subject", "", "subject")
+	newCmd.Flags().StringVarP(&newOpts.Body, "body", "b", "", "body")
+	newCmd.Flags().StringVarP(&newOpts.Format, "format", "f", "", "format of the body [markdown, plain, html]")
+	newCmd.Flags().StringArrayVar(&newOpts.Attachments, "attach", nil, "file to attach, repeatable")
+	newCmd.Flags().BoolVarP(&newOpts.Edit, "edit", "e", false, "write a draft and open it in $EDITOR")
+	newCmd.Flags().BoolVar(&newOpts.Draft, "draft", false, "write a draft that is not sent until its Draft header is removed")
+
+	for _, flag := range []string{"to", "cc", "bcc"} {
+		err := newCmd.RegisterFlagCompletionFunc(flag, draft.CompleteAddresses(fs, completionDirectory))
+		cobra.CheckErr(err)
+	}
+
+	err := newCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(
+		[]string{"markdown", "plain", "html"}, cobra.ShellCompDirectiveNoFileComp,
+	))
+	cobra.CheckErr(err)
+
+	rootCmd.AddCommand(newCmd)
+}
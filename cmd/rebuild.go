@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/spf13/cobra"
+)
+
+// rebuildCmd represents the rebuild command
+var rebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "rewrites received message files from their stored raw messages",
+	Long: `Rewrites the message files of every synchronized mailbox from the .eml files stored with keepRaw, using the
+current filenameTemplate, messageFormat and keepHTML settings. Nothing is downloaded. Flags and tags in the current
+files are kept, and messages without a stored raw message are left untouched.`,
+	Args: cobra.ExactArgs(0),
+	RunE: sync.RebuildRunE(log, fs, &accountDirectory),
+}
+
+func init() {
+	rootCmd.AddCommand(rebuildCmd)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/draft"
+	"github.com/spf13/cobra"
+)
+
+var replyOpts draft.Options
+
+// replyCmd represents the reply command
+var replyCmd = &cobra.Command{
+	Use:   "reply <file>",
+	Short: "writes a draft answering a message into the outbox",
+	Long: `Writes a draft answering a message file into the outbox directory, quoting the original message.
+The draft is not sent until its Draft header is removed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: draft.ReplyRunE(log, fs, &selection, &replyOpts),
+}
+
+func init() {
+	replyCmd.Flags().BoolVarP(&replyOpts.All, "all", "a", false, "reply to everyone involved in the message")
+	replyCmd.Flags().BoolVarP(&replyOpts.Edit, "edit", "e", false, "open the draft in $EDITOR")
+
+	rootCmd.AddCommand(replyCmd)
+}
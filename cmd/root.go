@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/deifyed/fsmail/pkg/accounts"
 	"github.com/deifyed/fsmail/pkg/config"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/deifyed/fsmail/pkg/fsconv"
 	"github.com/deifyed/fsmail/pkg/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -13,16 +18,29 @@ import (
 )
 
 var (
-	logLevel string
-	cfgFile  string
-	log      = &logrus.Logger{}
-	fs       = &afero.Afero{Fs: afero.NewOsFs()}
+	logLevel          string
+	cfgFile           string
+	imapServerAddress string
+	smtpServerAddress string
+	targetDir         string
+	accountName       string
+	log               = &logrus.Logger{}
+	fs                = &afero.Afero{Fs: afero.NewOsFs()}
+
+	// selection contains the configured accounts, and accountDirectory the work directory of the selected account
+	selection        accounts.Selection
+	accountDirectory string
 )
 
+const defaultMaxDeletions = 10
+
 var rootCmd = &cobra.Command{
 	Use:          "fsmail",
 	Short:        "fsmail enables you to synchronize your local directory with your email account",
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return selectAccount(cmd)
+	},
 }
 
 func Execute() {
@@ -38,10 +56,38 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default $HOME/.fssmtp.yaml)")
 
+	viper.SetDefault(config.MaxDeletions, defaultMaxDeletions)
+	viper.SetDefault(config.DefaultFormat, email.FormatMarkdown)
+	viper.SetDefault(config.FilenameTemplate, fsconv.DefaultFilenameTemplate)
+	viper.SetDefault(config.MessageFormat, string(frontmatter.FormatHeaders))
+	viper.SetDefault(config.Storage, sync.StorageFiles)
+	viper.SetDefault(config.CredentialStore, accounts.StoreKeyring)
+
 	viper.SetDefault(config.LogLevel, "info")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", viper.GetString(config.LogLevel), "log level [debug, info]")
 	err = viper.BindPFlag(config.LogLevel, rootCmd.PersistentFlags().Lookup("log-level"))
 	cobra.CheckErr(err)
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Errorf("getting work directory: %w", err))
+	}
+
+	rootCmd.PersistentFlags().StringVarP(&targetDir, "directory", "d", workDir, "target directory")
+	err = viper.BindPFlag(config.WorkingDirectory, rootCmd.PersistentFlags().Lookup("directory"))
+	cobra.CheckErr(err)
+
+	rootCmd.PersistentFlags().StringVar(&accountName, "account", "", "account to use, defaults to the first configured account")
+	err = viper.BindPFlag(config.Account, rootCmd.PersistentFlags().Lookup("account"))
+	cobra.CheckErr(err)
+
+	rootCmd.PersistentFlags().StringVarP(&imapServerAddress, "imap-server-address", "i", "", "IMAP server address")
+	err = viper.BindPFlag(config.IMAPServerAddress, rootCmd.PersistentFlags().Lookup("imap-server-address"))
+	cobra.CheckErr(err)
+
+	rootCmd.PersistentFlags().StringVarP(&smtpServerAddress, "smtp-server-address", "s", "", "SMTP server address")
+	err = viper.BindPFlag(config.SMTPServerAddress, rootCmd.PersistentFlags().Lookup("smtp-server-address"))
+	cobra.CheckErr(err)
 }
 
 func initConfig() {
@@ -72,3 +118,63 @@ func initConfig() {
 
 	log.Debug(msg)
 }
+
+// selectAccount picks the account to work with. Server addresses given as flags take precedence over the settings of
+// the account
+func selectAccount(cmd *cobra.Command) error {
+	var configured []accounts.Account
+
+	err := viper.UnmarshalKey(config.Accounts, &configured)
+	if err != nil {
+		return fmt.Errorf("reading accounts: %w", err)
+	}
+
+	defaults := accounts.Account{
+		IMAPServerAddress: viper.GetString(config.IMAPServerAddress),
+		SMTPServerAddress: viper.GetString(config.SMTPServerAddress),
+		Directory:         targetDir,
+		CredentialStore:   viper.GetString(config.CredentialStore),
+		PasswordCommand:   viper.GetString(config.PasswordCommand),
+		Username:          viper.GetString(config.Username),
+		CredentialsFile:   viper.GetString(config.CredentialsFile),
+		From:              viper.GetString(config.From),
+	}
+
+	err = viper.UnmarshalKey(config.OAuth2, &defaults.OAuth2)
+	if err != nil {
+		return fmt.Errorf("reading oauth2: %w", err)
+	}
+
+	selection, err = accounts.Select(configured, defaults, viper.GetString(config.Account))
+	if err != nil {
+		return fmt.Errorf("selecting account: %w", err)
+	}
+
+	if cmd.Flags().Changed("imap-server-address") {
+		selection.Current.IMAPServerAddress = imapServerAddress
+	}
+
+	if cmd.Flags().Changed("smtp-server-address") {
+		selection.Current.SMTPServerAddress = smtpServerAddress
+	}
+
+	accountDirectory = selection.Current.Directory
+
+	log.Debugf("Using account %q", selection.Current.Name)
+
+	return nil
+}
+
+// completionDirectory knows how to find the work directory of the account chosen by the flags of cmd while completing.
+// Cobra runs neither PersistentPreRunE nor initConfig after parsing the flags of the completed command, so both the
+// config file and the account are read here
+func completionDirectory(cmd *cobra.Command) (string, error) {
+	initConfig()
+
+	err := selectAccount(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return accountDirectory, nil
+}
@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+)
+
+// Addresses returns the addresses found in the messages of every mailbox directory recorded in the sync state of the
+// work directory. Messages are read with the storage they were stored with
+func Addresses(fs *afero.Afero, absoluteWorkDirectory string) ([]string, error) {
+	syncState, err := state.Load(fs, path.Join(absoluteWorkDirectory, state.Filename))
+	if err != nil {
+		return nil, fmt.Errorf("loading sync state: %w", err)
+	}
+
+	storageName := syncState.Storage
+	if storageName == "" {
+		storageName = StorageFiles
+	}
+
+	store, err := newStorage(fs, storageName, options{})
+	if err != nil {
+		return nil, fmt.Errorf("preparing storage: %w", err)
+	}
+
+	result := make([]string, 0)
+
+	for _, name := range sortedMailboxNames(syncState.Mailboxes) {
+		directory := syncState.Mailboxes[name].Directory
+
+		// State files written before mailbox directories were recorded get them on the next sync
+		if directory == "" {
+			continue
+		}
+
+		list, err := store.Addresses(path.Join(absoluteWorkDirectory, directory))
+		if err != nil {
+			return nil, fmt.Errorf("reading addresses in %s: %w", name, err)
+		}
+
+		result = append(result, list...)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"path"
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/maildir"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddresses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		withStorage     string
+		withMailboxes   map[string]string
+		withFiles       map[string]string
+		withMaildir     map[string][]string
+		expectAddresses []string
+	}{
+		{
+			name:          "Should read message files in every synced mailbox directory",
+			withMailboxes: map[string]string{"INBOX": "inbox", "Work/Projects": "Work/Projects"},
+			withFiles: map[string]string{
+				"inbox/Hello":          "---\nFrom: Jane Doe <jane@example.com>\nTo: me@example.com\n---\n\nHello\n",
+				"Work/Projects/Status": "---\nFrom: john@example.com\nCc: Team <team@example.com>, me@example.com\n---\n\nStatus\n",
+			},
+			expectAddresses: []string{
+				"Jane Doe <jane@example.com>", "me@example.com",
+				"john@example.com", "Team <team@example.com>", "me@example.com",
+			},
+		},
+		{
+			name:          "Should skip directories that are not synced and files that are not messages",
+			withMailboxes: map[string]string{"INBOX": "inbox"},
+			withFiles: map[string]string{
+				"inbox/Hello":       "---\nFrom: jane@example.com\n---\n\nHello\n",
+				"inbox/Hello.eml":   "From: someone@example.com\r\n\r\nHello\r\n",
+				"inbox/notes.txt":   "Just some notes",
+				"Archive/Old":       "---\nFrom: old@example.com\n---\n\nOld\n",
+				"outbox/Unfinished": "---\nTo: draft@example.com\n---\n\nLater\n",
+			},
+			expectAddresses: []string{"jane@example.com"},
+		},
+		{
+			name:          "Should read messages in a Maildir",
+			withStorage:   StorageMaildir,
+			withMailboxes: map[string]string{"INBOX": "inbox", "Sent Items": "Sent Items"},
+			withMaildir: map[string][]string{
+				"inbox": {
+					"From: =?utf-8?q?J=C3=B8rgen?= <jorgen@example.com>\r\nTo: me@example.com\r\n\r\nHei\r\n",
+					"Subject: No header with addresses\r\n\r\nHello\r\n",
+				},
+				"Sent Items": {"From: me@example.com\r\nBcc: secret@example.com\r\n\r\nHello\r\n"},
+			},
+			expectAddresses: []string{
+				"Jørgen <jorgen@example.com>", "me@example.com", "me@example.com", "secret@example.com",
+			},
+		},
+		{
+			name:            "Should find nothing in a directory that was never synced",
+			expectAddresses: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			syncState := state.State{Storage: tc.withStorage, Mailboxes: make(map[string]state.Mailbox)}
+
+			for name, directory := range tc.withMailboxes {
+				syncState.Mailboxes[name] = state.Mailbox{Directory: directory, UIDValidity: 1}
+			}
+
+			for filePath, content := range tc.withFiles {
+				err := fs.WriteFile(path.Join("/mail", filePath), []byte(content), 0o600)
+				assert.NoError(t, err)
+			}
+
+			for directory, messages := range tc.withMaildir {
+				for _, message := range messages {
+					_, err := maildir.Deliver(fs, path.Join("/mail", directory), []byte(message), nil)
+					assert.NoError(t, err)
+				}
+			}
+
+			if len(syncState.Mailboxes) > 0 {
+				err := state.Save(fs, path.Join("/mail", state.Filename), syncState)
+				assert.NoError(t, err)
+			}
+
+			result, err := Addresses(fs, "/mail")
+			assert.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expectAddresses, result)
+		})
+	}
+}
@@ -1,51 +1,141 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"path/filepath"
 
+	"github.com/deifyed/fsmail/pkg/accounts"
 	"github.com/deifyed/fsmail/pkg/config"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/deifyed/fsmail/pkg/fsconv"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-func RunE(log logger, fs *afero.Afero, targetDir *string) func(*cobra.Command, []string) error {
+// Options contains the flags of the sync command
+type Options struct {
+	// All synchronizes every configured account instead of the selected one
+	All bool
+}
+
+var (
+	errAccountConflict = errors.New("conflicting accounts")
+	errSyncFailed      = errors.New("synchronization failed")
+)
+
+func RunE(log logger, fs *afero.Afero, selection *accounts.Selection, syncOpts *Options) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		absoluteWorkDirectory, err := filepath.Abs(*targetDir)
-		if err != nil {
-			return fmt.Errorf("acquiring absolute target dir: %w", err)
+		if !syncOpts.All {
+			return synchronize(log, fs, selection.Current)
 		}
 
-		absoluteInboxDirectory := path.Join(absoluteWorkDirectory, "inbox")
-		absoluteOutboxDirectory := path.Join(absoluteWorkDirectory, "outbox")
-		absoluteSentDirectory := path.Join(absoluteWorkDirectory, "sent")
-
-		imapServerAddress := viper.GetString(config.IMAPServerAddress)
-		smtpServerAddress := viper.GetString(config.SMTPServerAddress)
+		if viper.GetString(config.Account) != "" {
+			return fmt.Errorf("--all synchronizes every account and can not be combined with --account: %w",
+				errAccountConflict)
+		}
 
-		log.Debugf("Using work dir: %s", absoluteWorkDirectory)
-		log.Debugf("Using IMAP server address: %s", imapServerAddress)
-		log.Debugf("Using SMTP server address: %s", smtpServerAddress)
+		// A failing account should not keep the others from being synchronized
+		failed := 0
 
-		log.Debug("Preparing credentials")
+		for _, account := range selection.All {
+			log.Infof("Synchronizing account %s", account.DisplayName())
 
-		creds, err := acquireCredentials(imapServerAddress, smtpServerAddress)
-		if err != nil {
-			return fmt.Errorf("acquiring credentials: %w", err)
-		}
+			err := synchronize(log, fs, account)
+			if err != nil {
+				log.Warn(fmt.Sprintf("Synchronizing account %s failed: %s", account.DisplayName(), err))
 
-		err = handleInbox(log, fs, absoluteInboxDirectory, creds)
-		if err != nil {
-			return fmt.Errorf("handling inbox: %w", err)
+				failed++
+			}
 		}
 
-		err = handleOutbox(log, fs, absoluteOutboxDirectory, absoluteSentDirectory, creds)
-		if err != nil {
-			return fmt.Errorf("handling outbox: %w", err)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d accounts: %w", failed, len(selection.All), errSyncFailed)
 		}
 
 		return nil
 	}
 }
+
+func synchronize(log logger, fs *afero.Afero, account accounts.Account) error {
+	opts, creds, err := prepare(log, fs, account)
+	if err != nil {
+		return fmt.Errorf("preparing: %w", err)
+	}
+
+	err = handleMailboxes(log, fs, opts, creds)
+	if err != nil {
+		return fmt.Errorf("handling mailboxes: %w", err)
+	}
+
+	err = handleOutbox(log, fs, opts.absoluteOutboxDirectory, opts.absoluteSentDirectory, opts.defaultFormat, creds)
+	if err != nil {
+		return fmt.Errorf("handling outbox: %w", err)
+	}
+
+	return nil
+}
+
+func prepare(log logger, fs *afero.Afero, account accounts.Account) (options, email.Credentials, error) {
+	opts, err := prepareOptions(fs, account.Directory)
+	if err != nil {
+		return options{}, email.Credentials{}, err
+	}
+
+	log.Debugf("Using work dir: %s", opts.absoluteWorkDirectory)
+	log.Debugf("Using IMAP server address: %s", account.IMAPServerAddress)
+	log.Debugf("Using SMTP server address: %s", account.SMTPServerAddress)
+
+	log.Debug("Preparing credentials")
+
+	creds, err := account.Credentials(fs)
+	if err != nil {
+		return options{}, email.Credentials{}, fmt.Errorf("acquiring credentials: %w", err)
+	}
+
+	return opts, creds, nil
+}
+
+// prepareOptions collects the options that don't require a connection to the server
+func prepareOptions(fs *afero.Afero, targetDir string) (options, error) {
+	absoluteWorkDirectory, err := filepath.Abs(targetDir)
+	if err != nil {
+		return options{}, fmt.Errorf("acquiring absolute target dir: %w", err)
+	}
+
+	opts := options{
+		absoluteWorkDirectory:   absoluteWorkDirectory,
+		absoluteOutboxDirectory: path.Join(absoluteWorkDirectory, outboxDirectoryName),
+		absoluteSentDirectory:   path.Join(absoluteWorkDirectory, sentDirectoryName),
+		filter: mailboxFilter{
+			include: viper.GetStringSlice(config.IncludeFolders),
+			exclude: viper.GetStringSlice(config.ExcludeFolders),
+		},
+		maxDeletions:  viper.GetInt(config.MaxDeletions),
+		expungeAll:    viper.GetBool(config.ExpungeAll),
+		defaultFormat: viper.GetString(config.DefaultFormat),
+		keepHTML:      viper.GetBool(config.KeepHTML),
+		keepRaw:       viper.GetBool(config.KeepRaw),
+		storageName:   viper.GetString(config.Storage),
+	}
+
+	opts.namer, err = fsconv.NewNamer(viper.GetString(config.FilenameTemplate))
+	if err != nil {
+		return options{}, fmt.Errorf("preparing filename template: %w", err)
+	}
+
+	opts.messageFormat, err = frontmatter.ParseFormat(viper.GetString(config.MessageFormat))
+	if err != nil {
+		return options{}, fmt.Errorf("preparing message format: %w", err)
+	}
+
+	opts.storage, err = newStorage(fs, opts.storageName, opts)
+	if err != nil {
+		return options{}, fmt.Errorf("preparing storage: %w", err)
+	}
+
+	return opts, nil
+}
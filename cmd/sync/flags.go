@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/state"
+)
+
+// synchronizeFlags merges flag changes made locally and on the server since the last sync, and applies the result to
+// both sides. Messages that no longer exist on the server stop being tracked
+func synchronizeFlags(log logger, store storage, client *email.IMAPClient, absoluteMailboxDirectory string, messages map[uint32]state.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	remoteFlags, err := client.FetchFlags()
+	if err != nil {
+		return fmt.Errorf("fetching flags: %w", err)
+	}
+
+	for uid, message := range messages {
+		rawRemote, ok := remoteFlags[uid]
+		if !ok {
+			log.Debugf("Message %s no longer exists on the server", message.Path)
+
+			delete(messages, uid)
+
+			continue
+		}
+
+		exists, err := store.Exists(absoluteMailboxDirectory, message.Path)
+		if err != nil {
+			return fmt.Errorf("checking existence of %s: %w", message.Path, err)
+		}
+
+		if !exists {
+			continue
+		}
+
+		local, err := store.ReadFlags(absoluteMailboxDirectory, message.Path)
+		if err != nil {
+			return fmt.Errorf("reading flags of %s: %w", message.Path, err)
+		}
+
+		remote := flags.FromIMAP(rawRemote)
+		merged := flags.Merge(message.Flags, local, remote)
+
+		added, removed := flags.Diff(remote, merged)
+		if len(added) > 0 || len(removed) > 0 {
+			log.Debugf("Updating flags of %s on the server", message.Path)
+
+			err = client.StoreFlags(uid, flags.ToIMAP(added), flags.ToIMAP(removed))
+			if err != nil {
+				return fmt.Errorf("storing flags of %s: %w", message.Path, err)
+			}
+		}
+
+		added, removed = flags.Diff(local, merged)
+		if len(added) > 0 || len(removed) > 0 {
+			log.Debugf("Updating flags of %s locally", message.Path)
+
+			err = store.UpdateFlags(absoluteMailboxDirectory, message.Path, merged)
+			if err != nil {
+				return fmt.Errorf("updating flags of %s: %w", message.Path, err)
+			}
+		}
+
+		message.Flags = merged
+		messages[uid] = message
+	}
+
+	return nil
+}
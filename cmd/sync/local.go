@@ -0,0 +1,368 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/deifyed/fsmail/pkg/folders"
+	"github.com/deifyed/fsmail/pkg/state"
+)
+
+var errTooManyDeletions = errors.New("too many deletions")
+
+// remoteMailboxes knows how to apply local changes to the mailboxes on the server
+type remoteMailboxes interface {
+	Select(name string) (uint32, error)
+	Delete(uids []uint32, expungeAll bool) error
+	Move(uids []uint32, destination string, expungeAll bool) error
+	SearchMessageID(messageID string) ([]uint32, error)
+}
+
+// localChanges describes the tracked messages that disappeared from their path in a mailbox directory since the last
+// sync
+type localChanges struct {
+	deleted []uint32
+	// renamed maps the UIDs of messages renamed within the mailbox directory to their new path
+	renamed map[uint32]string
+	// moved maps the destination mailbox name to the messages moved into its directory
+	moved map[string][]movedMessage
+}
+
+// movedMessage is a tracked message found in the directory of another mailbox
+type movedMessage struct {
+	uid uint32
+	// path identifies the message in the directory of the destination mailbox
+	path      string
+	messageID string
+}
+
+// propagateLocalChanges detects message files that have been deleted, renamed or moved to another mailbox directory
+// since the last sync, and applies the same change on the server. A missing file is looked for among the files that
+// are not yet tracked, first in its own mailbox directory, then in the directories of the other mailboxes, including
+// the excluded ones. Files are matched by Message-ID, or by name for messages downloaded before their Message-ID was
+// recorded
+func propagateLocalChanges(log logger, client remoteMailboxes, mailboxes []syncedMailbox, excluded []syncedMailbox, syncState state.State, opts options) error {
+	changes := make(map[string]localChanges)
+	deletionCount := 0
+
+	// Moving a file into the directory of an excluded mailbox is a move as well. Taking it for a deletion would
+	// expunge the message
+	destinations := make(map[string]syncedMailbox, len(mailboxes)+len(excluded))
+	candidates := append(append(make([]syncedMailbox, 0, len(mailboxes)+len(excluded)), mailboxes...), excluded...)
+
+	for _, mailbox := range candidates {
+		destinations[mailbox.Name] = mailbox
+	}
+
+	untracked := untrackedMessages{store: opts.storage, syncState: syncState, indexes: make(map[string]map[string]string)}
+
+	for _, mailbox := range mailboxes {
+		mailboxChanges, err := detectLocalChanges(opts.storage, mailbox, candidates, syncState, untracked)
+		if err != nil {
+			return fmt.Errorf("detecting changes in %s: %w", mailbox.Name, err)
+		}
+
+		changes[mailbox.Name] = mailboxChanges
+		deletionCount += len(mailboxChanges.deleted)
+	}
+
+	if deletionCount > opts.maxDeletions {
+		return fmt.Errorf("refusing to delete %d messages, the limit is %d: %w", deletionCount, opts.maxDeletions, errTooManyDeletions)
+	}
+
+	// arrivals maps the destination mailbox name to the messages moved into it, as they are to be tracked there
+	arrivals := make(map[string][]state.Message)
+
+	for _, mailbox := range mailboxes {
+		mailboxChanges := changes[mailbox.Name]
+		mailboxState := syncState.Mailboxes[mailbox.Name]
+
+		// Renaming a file changes nothing on the server
+		for uid, messagePath := range mailboxChanges.renamed {
+			log.Debugf("Tracking %s as %s", mailboxState.Messages[uid].Path, messagePath)
+
+			message := mailboxState.Messages[uid]
+			message.Path = messagePath
+			mailboxState.Messages[uid] = message
+		}
+
+		if len(mailboxChanges.deleted) == 0 && len(mailboxChanges.moved) == 0 {
+			continue
+		}
+
+		uidValidity, err := client.Select(mailbox.Name)
+		if err != nil {
+			return fmt.Errorf("selecting %s: %w", mailbox.Name, err)
+		}
+
+		if uidValidity != mailboxState.UIDValidity {
+			log.Warn(fmt.Sprintf("Ignoring local changes in %s as its UIDVALIDITY changed", mailbox.Name))
+
+			continue
+		}
+
+		if len(mailboxChanges.deleted) > 0 {
+			log.Debugf("Deleting %d messages from %s", len(mailboxChanges.deleted), mailbox.Name)
+
+			err = client.Delete(mailboxChanges.deleted, opts.expungeAll)
+			if err != nil {
+				return fmt.Errorf("deleting messages from %s: %w", mailbox.Name, err)
+			}
+
+			err = forget(opts.storage, mailbox, mailboxState, mailboxChanges.deleted)
+			if err != nil {
+				return fmt.Errorf("forgetting deleted messages: %w", err)
+			}
+		}
+
+		for _, destination := range sortedKeys(mailboxChanges.moved) {
+			moved := mailboxChanges.moved[destination]
+			uids := make([]uint32, len(moved))
+
+			for index, message := range moved {
+				uids[index] = message.uid
+
+				arrivals[destination] = append(arrivals[destination], state.Message{
+					Path:      message.path,
+					Flags:     mailboxState.Messages[message.uid].Flags,
+					MessageID: message.messageID,
+				})
+			}
+
+			log.Debugf("Moving %d messages from %s to %s", len(uids), mailbox.Name, destination)
+
+			err = client.Move(uids, destination, opts.expungeAll)
+			if err != nil {
+				return fmt.Errorf("moving messages from %s: %w", mailbox.Name, err)
+			}
+
+			err = forget(opts.storage, mailbox, mailboxState, uids)
+			if err != nil {
+				return fmt.Errorf("forgetting moved messages: %w", err)
+			}
+		}
+	}
+
+	for _, destination := range sortedKeys(arrivals) {
+		err := trackMovedMessages(log, client, destinations[destination], arrivals[destination], syncState)
+		if err != nil {
+			return fmt.Errorf("tracking messages moved to %s: %w", destination, err)
+		}
+	}
+
+	return nil
+}
+
+func detectLocalChanges(store storage, mailbox syncedMailbox, candidates []syncedMailbox, syncState state.State, untracked untrackedMessages) (localChanges, error) {
+	changes := localChanges{renamed: make(map[uint32]string), moved: make(map[string][]movedMessage)}
+	messages := syncState.Mailboxes[mailbox.Name].Messages
+
+	// Going through the messages in UID order makes duplicates match the same files every time
+	for _, uid := range sortedUIDs(messages) {
+		message := messages[uid]
+
+		exists, err := store.Exists(mailbox.absoluteDirectory, message.Path)
+		if err != nil {
+			return localChanges{}, fmt.Errorf("checking existence of %s: %w", message.Path, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		// A renamed file can only be recognized by its Message-ID, as its name changed
+		if message.MessageID != "" {
+			renamedPath, _, err := untracked.claim(mailbox, message)
+			if err != nil {
+				return localChanges{}, fmt.Errorf("looking for %s in %s: %w", message.Path, mailbox.Name, err)
+			}
+
+			if renamedPath != "" {
+				changes.renamed[uid] = renamedPath
+
+				continue
+			}
+		}
+
+		destination, moved, err := findMoveDestination(mailbox, candidates, untracked, message)
+		if err != nil {
+			return localChanges{}, fmt.Errorf("looking for %s in other mailboxes: %w", message.Path, err)
+		}
+
+		if destination == "" {
+			changes.deleted = append(changes.deleted, uid)
+		} else {
+			moved.uid = uid
+			changes.moved[destination] = append(changes.moved[destination], moved)
+		}
+	}
+
+	return changes, nil
+}
+
+func findMoveDestination(source syncedMailbox, candidates []syncedMailbox, untracked untrackedMessages, message state.Message) (string, movedMessage, error) {
+	for _, candidate := range candidates {
+		if candidate.Name == source.Name {
+			continue
+		}
+
+		messagePath, messageID, err := untracked.claim(candidate, message)
+		if err != nil {
+			return "", movedMessage{}, fmt.Errorf("looking in %s: %w", candidate.Name, err)
+		}
+
+		if messagePath != "" {
+			return candidate.Name, movedMessage{path: messagePath, messageID: messageID}, nil
+		}
+	}
+
+	return "", movedMessage{}, nil
+}
+
+// untrackedMessages indexes the messages in mailbox directories that are not tracked, mapping their path to their
+// Message-ID. A mailbox directory is only read when a tracked message is missing
+type untrackedMessages struct {
+	store     storage
+	syncState state.State
+	indexes   map[string]map[string]string
+}
+
+// claim finds the untracked message in the directory of mailbox that is the tracked message. It is matched by
+// Message-ID, or by path when the Message-ID of the tracked message is unknown. A claimed message is never returned
+// again. It returns the path and Message-ID of the untracked message, or empty strings when there is none
+func (u untrackedMessages) claim(mailbox syncedMailbox, message state.Message) (string, string, error) {
+	index, ok := u.indexes[mailbox.Name]
+	if !ok {
+		all, err := u.store.Index(mailbox.absoluteDirectory)
+		if err != nil {
+			return "", "", fmt.Errorf("indexing: %w", err)
+		}
+
+		index = make(map[string]string, len(all))
+
+		for messagePath, messageID := range all {
+			if !isTracked(u.syncState.Mailboxes[mailbox.Name], messagePath) {
+				index[messagePath] = messageID
+			}
+		}
+
+		u.indexes[mailbox.Name] = index
+	}
+
+	for _, messagePath := range sortedKeys(index) {
+		messageID := index[messagePath]
+
+		if message.MessageID != "" && messageID == message.MessageID || message.MessageID == "" && messagePath == message.Path {
+			delete(index, messagePath)
+
+			return messagePath, messageID, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+func isTracked(mailbox state.Mailbox, messagePath string) bool {
+	for _, message := range mailbox.Messages {
+		if message.Path == messagePath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trackMovedMessages tracks messages moved into the directory of destination under the UIDs the server gave them when
+// moving them, found by their Message-ID. Otherwise, the next fetch would download them again next to the moved files
+func trackMovedMessages(log logger, client remoteMailboxes, destination syncedMailbox, messages []state.Message, syncState state.State) error {
+	uidValidity, err := client.Select(destination.Name)
+	if err != nil {
+		return fmt.Errorf("selecting %s: %w", destination.Name, err)
+	}
+
+	mailboxState := syncState.Mailboxes[destination.Name]
+
+	// A changed UIDVALIDITY invalidates the tracked messages. Starting over here keeps handleMailbox from discarding the
+	// moved messages along with them
+	if mailboxState.UIDValidity != uidValidity {
+		mailboxState = state.Mailbox{Directory: mailboxState.Directory, UIDValidity: uidValidity}
+	}
+
+	// Excluded mailboxes have never been synced, so their directory is not recorded yet
+	if mailboxState.Directory == "" {
+		mailboxState.Directory = folders.ToDirectory(destination.Name, destination.Delimiter)
+	}
+
+	if mailboxState.Messages == nil {
+		mailboxState.Messages = make(map[uint32]state.Message)
+	}
+
+	for _, message := range messages {
+		uid, err := findMovedUID(client, mailboxState, message.MessageID)
+		if err != nil {
+			return fmt.Errorf("looking up %s: %w", message.Path, err)
+		}
+
+		if uid == 0 {
+			log.Warn(fmt.Sprintf("Unable to find the moved message %s in %s, it will be downloaded again",
+				message.Path, destination.Name))
+
+			continue
+		}
+
+		mailboxState.Messages[uid] = message
+	}
+
+	syncState.Mailboxes[destination.Name] = mailboxState
+
+	return nil
+}
+
+// findMovedUID returns the highest UID of the messages with messageID that is not tracked yet, or 0 when there is none
+func findMovedUID(client remoteMailboxes, mailboxState state.Mailbox, messageID string) (uint32, error) {
+	if messageID == "" {
+		return 0, nil
+	}
+
+	uids, err := client.SearchMessageID(messageID)
+	if err != nil {
+		return 0, err
+	}
+
+	var result uint32
+
+	for _, uid := range uids {
+		if _, tracked := mailboxState.Messages[uid]; !tracked && uid > result {
+			result = uid
+		}
+	}
+
+	return result, nil
+}
+
+// forget stops tracking messages and removes what they left behind in the mailbox directory
+func forget(store storage, mailbox syncedMailbox, mailboxState state.Mailbox, uids []uint32) error {
+	for _, uid := range uids {
+		err := store.Forget(mailbox.absoluteDirectory, mailboxState.Messages[uid].Path)
+		if err != nil {
+			return fmt.Errorf("removing sidecars of %s: %w", mailboxState.Messages[uid].Path, err)
+		}
+
+		delete(mailboxState.Messages, uid)
+	}
+
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagateLocalChanges(t *testing.T) {
+	testCases := []struct {
+		name              string
+		withMessages      map[string]map[uint32]state.Message
+		withFiles         map[string]string
+		withSearchResults map[string][]uint32
+		expectMessages    map[string]map[uint32]state.Message
+		expectDeleted     map[string][]uint32
+		expectMoved       map[string][]uint32
+		expectErr         error
+	}{
+		{
+			name: "Should delete messages whose file is gone",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX": {},
+			},
+			expectDeleted: map[string][]uint32{"INBOX": {1}},
+		},
+		{
+			name: "Should keep tracking a renamed file under its new name",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			withFiles: map[string]string{"inbox/Greeting": messageFile("hello@example.com")},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Greeting", MessageID: "hello@example.com"}},
+			},
+		},
+		{
+			name: "Should move a file moved to another mailbox and track it under its new UID",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", Flags: []string{"seen"}, MessageID: "hello@example.com"}},
+			},
+			withFiles:         map[string]string{"Archive/Hello": messageFile("hello@example.com")},
+			withSearchResults: map[string][]uint32{"hello@example.com": {7}},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX":   {},
+				"Archive": {7: {Path: "Hello", Flags: []string{"seen"}, MessageID: "hello@example.com"}},
+			},
+			expectMoved: map[string][]uint32{"INBOX -> Archive": {1}},
+		},
+		{
+			name: "Should move a file renamed while moving it",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			withFiles:         map[string]string{"Archive/Greeting": messageFile("hello@example.com")},
+			withSearchResults: map[string][]uint32{"hello@example.com": {7}},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX":   {},
+				"Archive": {7: {Path: "Greeting", MessageID: "hello@example.com"}},
+			},
+			expectMoved: map[string][]uint32{"INBOX -> Archive": {1}},
+		},
+		{
+			name: "Should recognize moved files by name when the Message-ID is unknown",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello"}},
+			},
+			withFiles:         map[string]string{"Archive/Hello": messageFile("hello@example.com")},
+			withSearchResults: map[string][]uint32{"hello@example.com": {7}},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX":   {},
+				"Archive": {7: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			expectMoved: map[string][]uint32{"INBOX -> Archive": {1}},
+		},
+		{
+			name: "Should not mistake another message with the same name for a moved one",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			withFiles: map[string]string{"Archive/Hello": messageFile("other@example.com")},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX": {},
+			},
+			expectDeleted: map[string][]uint32{"INBOX": {1}},
+		},
+		{
+			name: "Should move a file moved into the directory of an excluded mailbox instead of deleting it",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {1: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			withFiles:         map[string]string{"Spam/Hello": messageFile("hello@example.com")},
+			withSearchResults: map[string][]uint32{"hello@example.com": {3}},
+			expectMessages: map[string]map[uint32]state.Message{
+				"INBOX": {},
+				"Spam":  {3: {Path: "Hello", MessageID: "hello@example.com"}},
+			},
+			expectMoved: map[string][]uint32{"INBOX -> Spam": {1}},
+		},
+		{
+			name: "Should refuse to delete more messages than allowed",
+			withMessages: map[string]map[uint32]state.Message{
+				"INBOX": {
+					1: {Path: "Hello", MessageID: "hello@example.com"},
+					2: {Path: "Bye", MessageID: "bye@example.com"},
+				},
+			},
+			expectErr: errTooManyDeletions,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.Out = io.Discard
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			for filePath, content := range tc.withFiles {
+				err := fs.WriteFile(path.Join("/mail", filePath), []byte(content), 0o600)
+				assert.NoError(t, err)
+			}
+
+			syncState := state.State{Mailboxes: make(map[string]state.Mailbox)}
+
+			for name, messages := range tc.withMessages {
+				syncState.Mailboxes[name] = state.Mailbox{UIDValidity: 1, Messages: messages}
+			}
+
+			mailboxes := []syncedMailbox{
+				{Mailbox: email.Mailbox{Name: "INBOX"}, absoluteDirectory: "/mail/inbox"},
+				{Mailbox: email.Mailbox{Name: "Archive"}, absoluteDirectory: "/mail/Archive"},
+			}
+
+			excluded := []syncedMailbox{
+				{Mailbox: email.Mailbox{Name: "Spam"}, absoluteDirectory: "/mail/Spam"},
+			}
+
+			remote := &mockRemote{searchResults: tc.withSearchResults}
+
+			opts := options{storage: fileStorage{fs: fs}, maxDeletions: 1}
+
+			err := propagateLocalChanges(log, remote, mailboxes, excluded, syncState, opts)
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+
+			for name, messages := range tc.expectMessages {
+				assert.Equal(t, messages, syncState.Mailboxes[name].Messages, name)
+			}
+
+			// Moved messages are tracked in mailboxes that have never been synced as well
+			for _, mailbox := range excluded {
+				if _, tracked := syncState.Mailboxes[mailbox.Name]; tracked {
+					assert.Equal(t, mailbox.Name, syncState.Mailboxes[mailbox.Name].Directory)
+				}
+			}
+
+			assert.Equal(t, tc.expectDeleted, remote.deleted)
+			assert.Equal(t, tc.expectMoved, remote.moved)
+		})
+	}
+}
+
+func messageFile(messageID string) string {
+	return fmt.Sprintf("---\nMessage-ID: <%s>\nSubject: Hello\n---\n\nHello\n", messageID)
+}
+
+// mockRemote records the changes applied to the server. Every mailbox has the UIDVALIDITY 1
+type mockRemote struct {
+	selected string
+	// searchResults maps Message-IDs to the UIDs searching for them returns
+	searchResults map[string][]uint32
+	deleted       map[string][]uint32
+	// moved maps "source -> destination" to the moved UIDs
+	moved map[string][]uint32
+}
+
+func (m *mockRemote) Select(name string) (uint32, error) {
+	m.selected = name
+
+	return 1, nil
+}
+
+func (m *mockRemote) Delete(uids []uint32, _ bool) error {
+	if m.deleted == nil {
+		m.deleted = make(map[string][]uint32)
+	}
+
+	m.deleted[m.selected] = append(m.deleted[m.selected], uids...)
+
+	return nil
+}
+
+func (m *mockRemote) Move(uids []uint32, destination string, _ bool) error {
+	if m.moved == nil {
+		m.moved = make(map[string][]uint32)
+	}
+
+	key := m.selected + " -> " + destination
+	m.moved[key] = append(m.moved[key], uids...)
+
+	return nil
+}
+
+func (m *mockRemote) SearchMessageID(messageID string) ([]uint32, error) {
+	return m.searchResults[messageID], nil
+}
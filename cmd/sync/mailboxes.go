@@ -0,0 +1,299 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/folders"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+)
+
+type mailboxFilter struct {
+	include []string
+	exclude []string
+	// only restricts fetching to a single mailbox when set. Local changes are still propagated for every mailbox
+	only string
+}
+
+// syncedMailbox pairs a mailbox on the server with the directory it is mirrored into
+type syncedMailbox struct {
+	email.Mailbox
+	absoluteDirectory string
+}
+
+func handleMailboxes(log logger, fs *afero.Afero, opts options, creds email.Credentials) error {
+	absoluteStatePath := path.Join(opts.absoluteWorkDirectory, state.Filename)
+
+	syncState, err := state.Load(fs, absoluteStatePath)
+	if err != nil {
+		return fmt.Errorf("loading sync state: %w", err)
+	}
+
+	err = checkStorage(syncState, opts.storageName)
+	if err != nil {
+		return err
+	}
+
+	syncState.Storage = opts.storageName
+
+	client, err := email.DialIMAP(log, creds)
+	if err != nil {
+		return fmt.Errorf("connecting to IMAP server: %w", err)
+	}
+
+	defer func() {
+		_ = client.Close()
+	}()
+
+	mailboxes, excluded, err := selectMailboxes(log, client, opts.absoluteWorkDirectory, opts.filter)
+	if err != nil {
+		return fmt.Errorf("selecting mailboxes: %w", err)
+	}
+
+	err = propagateLocalChanges(log, client, mailboxes, excluded, syncState, opts)
+	if err != nil {
+		return fmt.Errorf("propagating local changes: %w", err)
+	}
+
+	err = state.Save(fs, absoluteStatePath, syncState)
+	if err != nil {
+		return fmt.Errorf("saving sync state: %w", err)
+	}
+
+	for _, mailbox := range mailboxes {
+		if opts.filter.only != "" && opts.filter.only != mailbox.Name {
+			continue
+		}
+
+		syncState.Mailboxes[mailbox.Name], err = handleMailbox(log, client, mailbox.Mailbox, mailbox.absoluteDirectory, syncState.Mailboxes[mailbox.Name], opts)
+		if err != nil {
+			return fmt.Errorf("handling mailbox %s: %w", mailbox.Name, err)
+		}
+
+		err = state.Save(fs, absoluteStatePath, syncState)
+		if err != nil {
+			return fmt.Errorf("saving sync state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkStorage makes sure messages are tracked with the storage backend they were stored with. Looking for them with
+// another backend would make them appear deleted, and delete them on the server
+func checkStorage(syncState state.State, storageName string) error {
+	previous := syncState.Storage
+
+	switch {
+	case previous == "" && len(syncState.Mailboxes) == 0:
+		return nil
+	case previous == "":
+		previous = StorageFiles
+	}
+
+	if previous != storageName {
+		return fmt.Errorf("messages in this directory are stored as %s, not %s. Switch storage in an empty directory: %w",
+			previous, storageName, errStorageMismatch)
+	}
+
+	return nil
+}
+
+// selectMailboxes returns the mailboxes to synchronize, and the mailboxes left out by the filter. Message files can
+// still be moved into the directories of the latter
+func selectMailboxes(log logger, client *email.IMAPClient, absoluteWorkDirectory string, filter mailboxFilter) ([]syncedMailbox, []syncedMailbox, error) {
+	mailboxes, err := client.ListMailboxes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+
+	selected := make([]syncedMailbox, 0, len(mailboxes))
+	excluded := make([]syncedMailbox, 0)
+
+	for _, mailbox := range mailboxes {
+		included, err := folders.Match(mailbox.Name, mailbox.Delimiter, filter.include, filter.exclude)
+		if err != nil {
+			return nil, nil, fmt.Errorf("filtering mailbox %s: %w", mailbox.Name, err)
+		}
+
+		absoluteMailboxDirectory := path.Join(absoluteWorkDirectory, folders.ToDirectory(mailbox.Name, mailbox.Delimiter))
+		reserved := isReservedDirectory(absoluteWorkDirectory, absoluteMailboxDirectory)
+
+		switch {
+		case !included:
+			log.Debugf("Skipping excluded mailbox %s", mailbox.Name)
+
+			if !reserved {
+				excluded = append(excluded, syncedMailbox{Mailbox: mailbox, absoluteDirectory: absoluteMailboxDirectory})
+			}
+		case reserved:
+			log.Warn(fmt.Sprintf("Skipping mailbox %s as it collides with the %s or %s directory",
+				mailbox.Name, outboxDirectoryName, sentDirectoryName))
+		default:
+			selected = append(selected, syncedMailbox{Mailbox: mailbox, absoluteDirectory: absoluteMailboxDirectory})
+		}
+	}
+
+	return selected, excluded, nil
+}
+
+func handleMailbox(log logger, client *email.IMAPClient, mailbox email.Mailbox, absoluteMailboxDirectory string, previous state.Mailbox, opts options) (state.Mailbox, error) {
+	uidValidity, err := client.Select(mailbox.Name)
+	if err != nil {
+		return state.Mailbox{}, fmt.Errorf("selecting mailbox: %w", err)
+	}
+
+	current := state.Mailbox{
+		Directory:   folders.ToDirectory(mailbox.Name, mailbox.Delimiter),
+		UIDValidity: uidValidity,
+		LastUID:     previous.LastUID,
+		Messages:    make(map[uint32]state.Message, len(previous.Messages)),
+	}
+
+	// stored maps the Message-IDs of the files already in the mailbox directory to their paths, so the messages they
+	// hold are not downloaded next to them again
+	var stored map[string][]string
+
+	if previous.UIDValidity == uidValidity {
+		for uid, message := range previous.Messages {
+			current.Messages[uid] = message
+		}
+	} else {
+		log.Debugf("UIDVALIDITY of %s changed from %d to %d, doing a full resync", mailbox.Name, previous.UIDValidity, uidValidity)
+
+		current.LastUID = 0
+
+		stored, err = storedMessages(opts.storage, absoluteMailboxDirectory)
+		if err != nil {
+			return state.Mailbox{}, fmt.Errorf("indexing stored messages: %w", err)
+		}
+	}
+
+	err = synchronizeFlags(log, opts.storage, client, absoluteMailboxDirectory, current.Messages)
+	if err != nil {
+		return state.Mailbox{}, fmt.Errorf("synchronizing flags: %w", err)
+	}
+
+	log.Debugf("Fetching messages in %s newer than UID %d", mailbox.Name, current.LastUID)
+
+	messages, err := client.FetchNew(current.LastUID)
+	if err != nil {
+		return state.Mailbox{}, fmt.Errorf("fetching messages: %w", err)
+	}
+
+	log.Debugf("Saving %d messages to %s", len(messages), absoluteMailboxDirectory)
+
+	return storeMessages(opts.storage, absoluteMailboxDirectory, messages, current, stored)
+}
+
+// storeMessages stores fetched messages in the mailbox directory and tracks them in current. A message already stored
+// in the directory, as listed in stored, is tracked where it is with the flags it has on the server
+func storeMessages(store storage, absoluteMailboxDirectory string, messages []email.Message, current state.Mailbox, stored map[string][]string) (state.Mailbox, error) {
+	for _, msg := range messages {
+		if msg.UID > current.LastUID {
+			current.LastUID = msg.UID
+		}
+
+		// Messages moved into the mailbox locally are already tracked, and stored where they were moved to
+		if _, ok := current.Messages[msg.UID]; ok {
+			continue
+		}
+
+		flagList := flags.FromIMAP(msg.Flags)
+
+		if paths := stored[msg.MessageID]; msg.MessageID != "" && len(paths) > 0 {
+			stored[msg.MessageID] = paths[1:]
+
+			err := store.UpdateFlags(absoluteMailboxDirectory, paths[0], flagList)
+			if err != nil {
+				return state.Mailbox{}, fmt.Errorf("updating flags of %s: %w", paths[0], err)
+			}
+
+			current.Messages[msg.UID] = state.Message{Path: paths[0], Flags: flagList, MessageID: msg.MessageID}
+
+			continue
+		}
+
+		messagePath, err := store.Write(absoluteMailboxDirectory, msg)
+		if err != nil {
+			return state.Mailbox{}, fmt.Errorf("storing message: %w", err)
+		}
+
+		current.Messages[msg.UID] = state.Message{
+			Path:      messagePath,
+			Flags:     flagList,
+			MessageID: msg.MessageID,
+		}
+	}
+
+	return current, nil
+}
+
+// storedMessages maps the Message-ID of every message stored in a mailbox directory to the paths of the messages
+func storedMessages(store storage, absoluteMailboxDirectory string) (map[string][]string, error) {
+	index, err := store.Index(absoluteMailboxDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string)
+
+	for _, messagePath := range sortedKeys(index) {
+		if messageID := index[messagePath]; messageID != "" {
+			result[messageID] = append(result[messageID], messagePath)
+		}
+	}
+
+	return result, nil
+}
+
+// isReservedDirectory knows if a mailbox directory is, or is inside, one of the local directories for outgoing mail.
+// Case is ignored, as e.g. a server folder named Sent is the sent directory on case-insensitive filesystems
+func isReservedDirectory(absoluteWorkDirectory string, absoluteMailboxDirectory string) bool {
+	relativePath, err := filepath.Rel(absoluteWorkDirectory, absoluteMailboxDirectory)
+	if err != nil {
+		return false
+	}
+
+	topDirectory := strings.SplitN(filepath.ToSlash(relativePath), "/", 2)[0]
+
+	for _, reserved := range []string{outboxDirectoryName, sentDirectoryName} {
+		if strings.EqualFold(topDirectory, reserved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func emailMessageToFsConvMessage(source email.Message) fsconv.Message {
+	attachments := make([]fsconv.Attachment, len(source.Attachments))
+
+	for index, attachment := range source.Attachments {
+		attachments[index] = fsconv.Attachment{Filename: attachment.Filename, Content: attachment.Content}
+	}
+
+	return fsconv.Message{
+		From:        source.From,
+		To:          source.To,
+		Cc:          source.Cc,
+		Bcc:         source.Bcc,
+		ReplyTo:     source.ReplyTo,
+		Subject:     source.Subject,
+		Date:        source.Date,
+		MessageID:   source.MessageID,
+		InReplyTo:   source.InReplyTo,
+		References:  source.References,
+		Flags:       flags.FromIMAP(source.Flags),
+		Body:        source.Body,
+		HTML:        source.HTML,
+		Raw:         source.Raw,
+		Attachments: attachments,
+	}
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReservedDirectory(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		withMailboxDirectory  string
+		expectReservedMailbox bool
+	}{
+		{
+			name:                  "Should reserve the outbox",
+			withMailboxDirectory:  "/mail/outbox",
+			expectReservedMailbox: true,
+		},
+		{
+			name:                  "Should reserve the sent directory regardless of case",
+			withMailboxDirectory:  "/mail/Sent",
+			expectReservedMailbox: true,
+		},
+		{
+			name:                  "Should reserve directories inside reserved directories",
+			withMailboxDirectory:  "/mail/SENT/2022",
+			expectReservedMailbox: true,
+		},
+		{
+			name:                 "Should allow other directories",
+			withMailboxDirectory: "/mail/inbox",
+		},
+		{
+			name:                 "Should allow reserved names deeper in the tree",
+			withMailboxDirectory: "/mail/[Gmail]/Sent",
+		},
+		{
+			name:                 "Should allow names starting with a reserved name",
+			withMailboxDirectory: "/mail/Sent Items",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectReservedMailbox, isReservedDirectory("/mail", tc.withMailboxDirectory))
+		})
+	}
+}
+
+func TestStoreMessagesAfterUIDValidityChange(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	err := fs.WriteFile("/mail/inbox/Hello", []byte(messageFile("hello@example.com")), 0o600)
+	assert.NoError(t, err)
+
+	namer, err := fsconv.NewNamer(fsconv.DefaultFilenameTemplate)
+	assert.NoError(t, err)
+
+	store := fileStorage{fs: fs, namer: namer}
+
+	stored, err := storedMessages(store, "/mail/inbox")
+	assert.NoError(t, err)
+
+	messages := []email.Message{
+		{UID: 4, MessageID: "hello@example.com", Subject: "Hello", Flags: []string{"\\Seen"}, Body: strings.NewReader("Hello")},
+		{UID: 5, MessageID: "new@example.com", Subject: "New", Body: strings.NewReader("New")},
+	}
+
+	current := state.Mailbox{Directory: "inbox", UIDValidity: 2, Messages: make(map[uint32]state.Message)}
+
+	current, err = storeMessages(store, "/mail/inbox", messages, current, stored)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint32(5), current.LastUID)
+	assert.Equal(t, state.Message{Path: "Hello", Flags: []string{"seen"}, MessageID: "hello@example.com"}, current.Messages[4])
+	assert.Equal(t, "new@example.com", current.Messages[5].MessageID)
+
+	files, err := fs.ReadDir("/mail/inbox")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2, "the stored message is not downloaded again")
+
+	flagList, err := store.ReadFlags("/mail/inbox", "Hello")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"seen"}, flagList)
+
+	exists, err := fs.Exists(path.Join("/mail/inbox", current.Messages[5].Path))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/deifyed/fsmail/pkg/mbox"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// ImportOptions contains the flags of the import command
+type ImportOptions struct {
+	// Mbox is the path of the mbox file to import
+	Mbox string
+	// Into is the folder to import into, relative to the work directory
+	Into string
+}
+
+// ExportOptions contains the flags of the export command
+type ExportOptions struct {
+	// Folder is the folder to export, relative to the work directory
+	Folder string
+}
+
+var errInvalidFolder = errors.New("invalid folder")
+
+// ImportRunE stores every message of an mbox file in a folder of the work directory. Imported messages are local, as
+// they are not tracked by the sync state. Their raw message is always kept, as the mbox file might be the only copy
+func ImportRunE(log logger, fs *afero.Afero, targetDir *string, importOpts *ImportOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		opts, absoluteFolderDirectory, err := prepareFolder(fs, *targetDir, importOpts.Into)
+		if err != nil {
+			return fmt.Errorf("preparing: %w", err)
+		}
+
+		opts.keepRaw = true
+
+		opts.storage, err = newStorage(fs, opts.storageName, opts)
+		if err != nil {
+			return fmt.Errorf("preparing storage: %w", err)
+		}
+
+		err = fs.MkdirAll(absoluteFolderDirectory, defaultDirectoryPermissions)
+		if err != nil {
+			return fmt.Errorf("creating folder: %w", err)
+		}
+
+		file, err := fs.Open(importOpts.Mbox)
+		if err != nil {
+			return fmt.Errorf("opening mbox file: %w", err)
+		}
+
+		defer func() {
+			_ = file.Close()
+		}()
+
+		imported, err := importMessages(log, opts.storage, absoluteFolderDirectory, mbox.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("importing: %w", err)
+		}
+
+		log.Infof("Imported %d messages into %s", imported, importOpts.Into)
+
+		return nil
+	}
+}
+
+// ExportRunE writes every message of a folder in the work directory to stdout as an mbox file
+func ExportRunE(log logger, fs *afero.Afero, targetDir *string, exportOpts *ExportOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		opts, absoluteFolderDirectory, err := prepareFolder(fs, *targetDir, exportOpts.Folder)
+		if err != nil {
+			return fmt.Errorf("preparing: %w", err)
+		}
+
+		exported, err := exportMessages(opts.storage, absoluteFolderDirectory, mbox.NewWriter(cmd.OutOrStdout()))
+		if err != nil {
+			return fmt.Errorf("exporting: %w", err)
+		}
+
+		log.Debugf("Exported %d messages from %s", exported, exportOpts.Folder)
+
+		return nil
+	}
+}
+
+// prepareFolder collects the options and resolves a folder, making sure it is inside the work directory and stored
+// with the configured storage
+func prepareFolder(fs *afero.Afero, targetDir string, folder string) (options, string, error) {
+	opts, err := prepareOptions(fs, targetDir)
+	if err != nil {
+		return options{}, "", err
+	}
+
+	cleanFolder := path.Clean(folder)
+
+	if folder == "" || path.IsAbs(cleanFolder) || cleanFolder == "." || strings.HasPrefix(cleanFolder, "..") {
+		return options{}, "", fmt.Errorf("%q must be a directory inside the work directory: %w", folder, errInvalidFolder)
+	}
+
+	absoluteFolderDirectory := path.Join(opts.absoluteWorkDirectory, cleanFolder)
+
+	if isReservedDirectory(opts.absoluteWorkDirectory, absoluteFolderDirectory) {
+		return options{}, "", fmt.Errorf("%q is reserved for outgoing and sent messages: %w", folder, errInvalidFolder)
+	}
+
+	syncState, err := state.Load(fs, path.Join(opts.absoluteWorkDirectory, state.Filename))
+	if err != nil {
+		return options{}, "", fmt.Errorf("loading sync state: %w", err)
+	}
+
+	err = checkStorage(syncState, opts.storageName)
+	if err != nil {
+		return options{}, "", fmt.Errorf("checking storage: %w", err)
+	}
+
+	return opts, absoluteFolderDirectory, nil
+}
+
+// importMessages stores every message read from reader and returns the amount of stored messages
+func importMessages(log logger, store storage, absoluteFolderDirectory string, reader *mbox.Reader) (int, error) {
+	imported := 0
+
+	for {
+		msg, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return imported, nil
+		} else if err != nil {
+			return imported, fmt.Errorf("reading message: %w", err)
+		}
+
+		// A message that can not be parsed is stored as it is, instead of failing the rest of the import
+		parsed, err := email.ParseMessage(msg.Raw)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Keeping message %d unparsed: %s", imported+1, err))
+
+			parsed = email.RawMessage(msg.Raw)
+		}
+
+		parsed.Flags = flags.ToIMAP(msg.Flags)
+
+		_, err = store.Write(absoluteFolderDirectory, parsed)
+		if err != nil {
+			return imported, fmt.Errorf("storing message %d: %w", imported+1, err)
+		}
+
+		imported++
+	}
+}
+
+// exportMessages writes every message in a folder to writer and returns the amount of written messages
+func exportMessages(store storage, absoluteFolderDirectory string, writer *mbox.Writer) (int, error) {
+	exported := 0
+
+	err := store.Walk(absoluteFolderDirectory, func(raw []byte, flagList []string) error {
+		err := writer.Write(mbox.Message{Raw: raw, Flags: flagList})
+		if err != nil {
+			return fmt.Errorf("writing message: %w", err)
+		}
+
+		exported++
+
+		return nil
+	})
+	if err != nil {
+		return exported, err
+	}
+
+	return exported, nil
+}
+
+// fsConvMessageToEmailMessage converts a message file into a message that can be rendered. The body of a message file
+// is written as plain text, as it is usually converted from a received message already
+func fsConvMessageToEmailMessage(source fsconv.Message) email.Message {
+	attachments := make([]email.Attachment, len(source.Attachments))
+
+	for index, attachment := range source.Attachments {
+		attachments[index] = email.Attachment{Filename: attachment.Filename, Content: attachment.Content}
+	}
+
+	body := source.Body
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+
+	return email.Message{
+		From:        source.From,
+		To:          source.To,
+		Cc:          source.Cc,
+		Bcc:         source.Bcc,
+		ReplyTo:     source.ReplyTo,
+		Subject:     source.Subject,
+		Date:        source.Date,
+		MessageID:   source.MessageID,
+		InReplyTo:   source.InReplyTo,
+		References:  source.References,
+		Format:      email.FormatPlain,
+		Body:        body,
+		Flags:       flags.ToIMAP(source.Flags),
+		Headers:     customHeaders(source.Headers),
+		Attachments: attachments,
+	}
+}
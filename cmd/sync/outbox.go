@@ -1,124 +1,266 @@
 package sync
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	stdfs "io/fs"
 
 	"github.com/deifyed/fsmail/pkg/convert"
-	"github.com/deifyed/fsmail/pkg/credentials"
 	"github.com/deifyed/fsmail/pkg/email"
-	"github.com/deifyed/fsmail/pkg/keyring"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
 	"github.com/spf13/afero"
 )
 
-func handleOutbox(log logger, fs *afero.Afero, absoluteOutboxDirectory string, absoluteSentDirectory string, creds credentials.Credentials) error {
+// outgoingMessage pairs a message in the outbox with the files that belong to it
+type outgoingMessage struct {
+	filename string
+	message  convert.Message
+	// absoluteAttachmentPaths contains the absolute paths of the attachments
+	absoluteAttachmentPaths []string
+}
+
+func handleOutbox(log logger, fs *afero.Afero, absoluteOutboxDirectory string, absoluteSentDirectory string, defaultFormat string, creds email.Credentials) error {
+	outgoing, err := readOutbox(fs, absoluteOutboxDirectory)
+	if err != nil {
+		return fmt.Errorf("reading outbox: %w", err)
+	}
+
+	if len(outgoing) == 0 {
+		return nil
+	}
+
+	messages := make([]email.Message, len(outgoing))
+	receiptMap := make(map[string]outgoingMessage)
+
+	for index, item := range outgoing {
+		messages[index], err = convertMessageToEmail(fs, item, defaultFormat)
+		if err != nil {
+			return fmt.Errorf("preparing %s: %w", item.filename, err)
+		}
+
+		receiptMap[email.CalculateReceipt(item.message.From, item.message.To, item.message.Subject, item.message.Body)] = item
+	}
+
+	receipts, err := email.SendMessages(log, creds, messages)
+	if err != nil {
+		log.Warn(fmt.Errorf("sending messages: %w", err).Error())
+	}
+
+	if len(receipts) == 0 {
+		return err
+	}
+
+	for _, receipt := range receipts {
+		moveErr := moveToSent(fs, absoluteOutboxDirectory, absoluteSentDirectory, receiptMap[receipt])
+		if moveErr != nil {
+			return fmt.Errorf("moving sent message: %w", moveErr)
+		}
+	}
+
+	return err
+}
+
+// readOutbox parses every message in the outbox and validates that their attachments exist. Drafts are skipped. Files
+// that can not be parsed are only accepted when another message attaches them
+func readOutbox(fs *afero.Afero, absoluteOutboxDirectory string) ([]outgoingMessage, error) {
 	files, err := fs.ReadDir(absoluteOutboxDirectory)
 	if err != nil {
-		return fmt.Errorf("reading outbox directory: %w", err)
+		return nil, fmt.Errorf("reading outbox directory: %w", err)
 	}
 
 	files = filterFiles(files)
 
-	messages := make([]email.Message, len(files))
-	receiptMap := make(map[string]string)
+	outgoing := make([]outgoingMessage, 0, len(files))
+	attached := make(map[string]bool)
+	parseErrors := make(map[string]error)
 
-	for index, file := range files {
+	for _, file := range files {
 		filename := file.Name()
-
 		filePath := path.Join(absoluteOutboxDirectory, filename)
 
-		f, err := fs.OpenFile(filePath, os.O_RDONLY, defaultFilePermissions)
+		raw, err := fs.ReadFile(filePath)
 		if err != nil {
-			return fmt.Errorf("opening file: %w", err)
+			return nil, fmt.Errorf("reading %s: %w", filename, err)
 		}
 
-		msg, err := convert.ToMessage(f)
+		msg, err := convert.ToMessage(bytes.NewReader(raw))
 		if err != nil {
-			return fmt.Errorf("converting file to message: %w", err)
+			parseErrors[filename] = err
+
+			continue
+		}
+
+		if msg.Draft {
+			continue
+		}
+
+		item := outgoingMessage{filename: filename, message: msg}
+
+		for _, attachment := range msg.Attachments {
+			absoluteAttachmentPath := resolveAttachmentPath(absoluteOutboxDirectory, attachment)
+
+			isFile, err := isRegularFile(fs, absoluteAttachmentPath)
+			if err != nil {
+				return nil, fmt.Errorf("checking attachment %s of %s: %w", attachment, filename, err)
+			}
+
+			if !isFile {
+				return nil, fmt.Errorf("validating %s: attachment %s: %w", filename, attachment, errMissingAttachment)
+			}
+
+			item.absoluteAttachmentPaths = append(item.absoluteAttachmentPaths, absoluteAttachmentPath)
+			attached[absoluteAttachmentPath] = true
 		}
 
-		receiptMap[email.CalculateReceipt(msg.From, msg.To, msg.Subject, msg.Body)] = filename
-		messages[index] = convertMessageToEmail(msg)
+		outgoing = append(outgoing, item)
+	}
+
+	for filename, err := range parseErrors {
+		if !attached[path.Join(absoluteOutboxDirectory, filename)] {
+			return nil, fmt.Errorf("converting %s to message: %w", filename, err)
+		}
 	}
 
-	receipts, err := email.SendMessages(log, email.Credentials{
-		SMTPServerAddress: creds.SMTPServerAddress,
-		Username:          creds.Username,
-		Password:          creds.Password,
-	}, messages)
+	return outgoing, nil
+}
+
+// moveToSent moves a sent message and the attachments located in the outbox into the sent directory, keeping the
+// relative paths between them intact
+func moveToSent(fs *afero.Afero, absoluteOutboxDirectory string, absoluteSentDirectory string, item outgoingMessage) error {
+	err := fs.MkdirAll(absoluteSentDirectory, defaultDirectoryPermissions)
 	if err != nil {
-		log.Warn(fmt.Errorf("sending messages: %w", err).Error())
+		return fmt.Errorf("creating sent directory: %w", err)
 	}
 
-	if len(receipts) == 0 {
-		return err
+	err = fs.Rename(path.Join(absoluteOutboxDirectory, item.filename), path.Join(absoluteSentDirectory, item.filename))
+	if err != nil {
+		return fmt.Errorf("moving file: %w", err)
 	}
 
-	for _, receipt := range receipts {
-		filename := receiptMap[receipt]
-		sourcePath := path.Join(absoluteOutboxDirectory, filename)
-		destinationPath := path.Join(absoluteSentDirectory, filename)
+	for _, absoluteAttachmentPath := range item.absoluteAttachmentPaths {
+		relativePath, err := filepath.Rel(absoluteOutboxDirectory, absoluteAttachmentPath)
+		if err != nil || strings.HasPrefix(relativePath, "..") {
+			continue
+		}
 
-		err = fs.Rename(sourcePath, destinationPath)
+		destinationPath := path.Join(absoluteSentDirectory, relativePath)
+
+		err = fs.MkdirAll(path.Dir(destinationPath), defaultDirectoryPermissions)
 		if err != nil {
-			return fmt.Errorf("moving file: %w", err)
+			return fmt.Errorf("creating attachment directory: %w", err)
+		}
+
+		err = fs.Rename(absoluteAttachmentPath, destinationPath)
+		if err != nil {
+			return fmt.Errorf("moving attachment %s: %w", relativePath, err)
 		}
 	}
 
-	return err
+	return nil
 }
 
-func convertMessageToEmail(msg convert.Message) email.Message {
-	return email.Message{
-		From:    msg.From,
-		To:      msg.To,
-		Subject: msg.Subject,
-		Body:    strings.NewReader(msg.Body),
+func resolveAttachmentPath(absoluteOutboxDirectory string, attachment string) string {
+	if path.IsAbs(attachment) {
+		return path.Clean(attachment)
 	}
-}
-
-const defaultFilePermissions = 0o600
 
-func filterFiles(files []stdfs.FileInfo) []stdfs.FileInfo {
-	var filteredFiles []stdfs.FileInfo
+	return path.Join(absoluteOutboxDirectory, attachment)
+}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+func isRegularFile(fs *afero.Afero, filePath string) (bool, error) {
+	info, err := fs.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, stdfs.ErrNotExist) {
+			return false, nil
 		}
 
-		filteredFiles = append(filteredFiles, file)
+		return false, err
 	}
 
-	return filteredFiles
+	return info.Mode().IsRegular(), nil
 }
 
-func acquireCredentials(imapServerAddress string, smtpServerAddress string) (credentials.Credentials, error) {
-	var err error
-	store := keyring.Client{Prefix: generatePrefix("")}
+func convertMessageToEmail(fs *afero.Afero, item outgoingMessage, defaultFormat string) (email.Message, error) {
+	attachments := make([]email.Attachment, len(item.absoluteAttachmentPaths))
+
+	for index, absoluteAttachmentPath := range item.absoluteAttachmentPaths {
+		content, err := fs.ReadFile(absoluteAttachmentPath)
+		if err != nil {
+			return email.Message{}, fmt.Errorf("reading attachment: %w", err)
+		}
+
+		filename := path.Base(absoluteAttachmentPath)
 
-	creds := credentials.Credentials{
-		IMAPServerAddress: imapServerAddress,
-		SMTPServerAddress: smtpServerAddress,
+		attachments[index] = email.Attachment{
+			Filename:    filename,
+			ContentType: email.DetectContentType(filename, content),
+			Content:     content,
+		}
 	}
 
-	creds.Username, err = store.Get(credentials.CredentialsSecretName, credentials.UsernameKey)
-	if err != nil {
-		return credentials.Credentials{}, fmt.Errorf("retrieving username: %w", err)
+	format := item.message.Format
+	if format == "" {
+		format = defaultFormat
 	}
 
-	creds.Password, err = store.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
-	if err != nil {
-		return credentials.Credentials{}, fmt.Errorf("retrieving password: %w", err)
+	return email.Message{
+		From:        item.message.From,
+		To:          item.message.To,
+		Cc:          item.message.Cc,
+		Bcc:         item.message.Bcc,
+		ReplyTo:     item.message.ReplyTo,
+		Subject:     item.message.Subject,
+		Date:        item.message.Date,
+		MessageID:   item.message.MessageID,
+		InReplyTo:   item.message.InReplyTo,
+		References:  item.message.References,
+		Format:      format,
+		Body:        strings.NewReader(item.message.Body),
+		Headers:     customHeaders(item.message.Headers),
+		Attachments: attachments,
+	}, nil
+}
+
+// customHeaders groups the additional header fields of an outbox file by key, keeping the spelling of their first
+// occurrence
+func customHeaders(headers []frontmatter.Header) map[string][]string {
+	if len(headers) == 0 {
+		return nil
 	}
 
-	return creds, nil
+	keys := make(map[string]string)
+	result := make(map[string][]string)
+
+	for _, header := range headers {
+		key, ok := keys[strings.ToLower(header.Key)]
+		if !ok {
+			key = header.Key
+			keys[strings.ToLower(header.Key)] = key
+		}
+
+		result[key] = append(result[key], header.Value)
+	}
+
+	return result
 }
 
-func generatePrefix(username string) string {
-	return "fssmtp"
+var errMissingAttachment = errors.New("missing attachment")
+
+func filterFiles(files []stdfs.FileInfo) []stdfs.FileInfo {
+	var filteredFiles []stdfs.FileInfo
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		filteredFiles = append(filteredFiles, file)
+	}
+
+	return filteredFiles
 }
@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/deifyed/fsmail/pkg/state"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var errUnsupportedStorage = errors.New("unsupported storage")
+
+// RebuildRunE rewrites the message files of every synchronized mailbox from the raw messages stored next to them,
+// applying the current filename template, message format and keepHTML setting without contacting the server
+func RebuildRunE(log logger, fs *afero.Afero, targetDir *string) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		opts, err := prepareOptions(fs, *targetDir)
+		if err != nil {
+			return fmt.Errorf("preparing: %w", err)
+		}
+
+		if opts.storageName != StorageFiles {
+			return fmt.Errorf("rebuilding requires the %s storage: %w", StorageFiles, errUnsupportedStorage)
+		}
+
+		absoluteStatePath := path.Join(opts.absoluteWorkDirectory, state.Filename)
+
+		syncState, err := state.Load(fs, absoluteStatePath)
+		if err != nil {
+			return fmt.Errorf("loading sync state: %w", err)
+		}
+
+		for _, name := range sortedMailboxNames(syncState.Mailboxes) {
+			mailboxState := syncState.Mailboxes[name]
+
+			if mailboxState.Directory == "" {
+				log.Warn(fmt.Sprintf("Skipping %s as its directory is unknown, sync it once to record it", name))
+
+				continue
+			}
+
+			absoluteMailboxDirectory := path.Join(opts.absoluteWorkDirectory, mailboxState.Directory)
+
+			rebuilt, err := rebuildMailbox(log, fs, absoluteMailboxDirectory, mailboxState, opts)
+			if err != nil {
+				return fmt.Errorf("rebuilding %s: %w", name, err)
+			}
+
+			err = state.Save(fs, absoluteStatePath, syncState)
+			if err != nil {
+				return fmt.Errorf("saving sync state: %w", err)
+			}
+
+			log.Infof("Rebuilt %d of %d messages in %s", rebuilt, len(mailboxState.Messages), name)
+		}
+
+		return nil
+	}
+}
+
+// rebuildMailbox rewrites the tracked messages of a mailbox that have a raw message, and updates their paths in
+// mailboxState. Messages whose file is gone are left alone, as the next sync deletes or moves them on the server. It
+// returns the amount of rebuilt messages
+func rebuildMailbox(log logger, fs *afero.Afero, absoluteMailboxDirectory string, mailboxState state.Mailbox, opts options) (int, error) {
+	rebuilt := 0
+
+	// Rebuilding in UID order makes collisions between the new filenames resolve the same way every time
+	for _, uid := range sortedUIDs(mailboxState.Messages) {
+		message := mailboxState.Messages[uid]
+
+		exists, err := fs.Exists(path.Join(absoluteMailboxDirectory, message.Path))
+		if err != nil {
+			return rebuilt, fmt.Errorf("checking existence of %s: %w", message.Path, err)
+		}
+
+		if !exists {
+			log.Debugf("Skipping %s as it has been removed locally", message.Path)
+
+			continue
+		}
+
+		raw, err := fs.ReadFile(path.Join(absoluteMailboxDirectory, fsconv.EMLSidecar(message.Path)))
+		if errors.Is(err, os.ErrNotExist) {
+			log.Debugf("Skipping %s as it has no raw message", message.Path)
+
+			continue
+		} else if err != nil {
+			return rebuilt, fmt.Errorf("reading raw message of %s: %w", message.Path, err)
+		}
+
+		// A message that can not be parsed keeps its current file, so the other messages can still be rebuilt
+		parsed, err := email.ParseMessage(raw)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Skipping %s as its raw message can not be parsed: %s", message.Path, err))
+
+			continue
+		}
+
+		filename, err := rebuildMessage(fs, absoluteMailboxDirectory, message, parsed, opts)
+		if err != nil {
+			return rebuilt, fmt.Errorf("rebuilding %s: %w", message.Path, err)
+		}
+
+		mailboxState.Messages[uid] = state.Message{Path: filename, Flags: message.Flags, MessageID: parsed.MessageID}
+		rebuilt++
+	}
+
+	return rebuilt, nil
+}
+
+// rebuildMessage replaces a message file and its sidecars with ones converted from the parsed raw message. Flags and
+// tags are taken from the current file, as they might have been changed locally. It returns the name of the new file
+func rebuildMessage(fs *afero.Afero, absoluteMailboxDirectory string, message state.Message, parsed email.Message, opts options) (string, error) {
+	fsconvMessage := emailMessageToFsConvMessage(parsed)
+	fsconvMessage.Flags = message.Flags
+
+	if !opts.keepHTML {
+		fsconvMessage.HTML = ""
+	}
+
+	current, err := fsconv.ReadMessage(fs, absoluteMailboxDirectory, message.Path)
+	if err == nil {
+		fsconvMessage.Flags = current.Flags
+		fsconvMessage.Tags = current.Tags
+	}
+
+	err = fs.Remove(path.Join(absoluteMailboxDirectory, message.Path))
+	if err != nil {
+		return "", fmt.Errorf("removing message file: %w", err)
+	}
+
+	err = fsconv.RemoveSidecars(fs, absoluteMailboxDirectory, message.Path)
+	if err != nil {
+		return "", fmt.Errorf("removing sidecars: %w", err)
+	}
+
+	filename, err := fsconv.WriteMessageToDirectory(
+		fs, absoluteMailboxDirectory, opts.namer, opts.messageFormat, fsconvMessage,
+	)
+	if err != nil {
+		return "", fmt.Errorf("writing message: %w", err)
+	}
+
+	return filename, nil
+}
+
+func sortedMailboxNames(mailboxes map[string]state.Mailbox) []string {
+	names := make([]string, 0, len(mailboxes))
+
+	for name := range mailboxes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func sortedUIDs(messages map[uint32]state.Message) []uint32 {
+	uids := make([]uint32, 0, len(messages))
+
+	for uid := range messages {
+		uids = append(uids, uid)
+	}
+
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	return uids
+}
@@ -0,0 +1,329 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path"
+
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/deifyed/fsmail/pkg/maildir"
+	"github.com/deifyed/fsmail/pkg/messageid"
+	"github.com/spf13/afero"
+)
+
+// storage knows how to keep the received messages of a mailbox directory. Messages are identified by the path recorded
+// in the sync state, which is relative to the mailbox directory
+type storage interface {
+	// Write stores a received message and returns the path identifying it
+	Write(absoluteMailboxDirectory string, message email.Message) (string, error)
+	// Exists knows if the message identified by messagePath is still in the mailbox directory
+	Exists(absoluteMailboxDirectory string, messagePath string) (bool, error)
+	// ReadFlags returns the local flags of a message
+	ReadFlags(absoluteMailboxDirectory string, messagePath string) ([]string, error)
+	// UpdateFlags replaces the local flags of a message
+	UpdateFlags(absoluteMailboxDirectory string, messagePath string, flagList []string) error
+	// Forget removes what a message that was deleted or moved locally left behind
+	Forget(absoluteMailboxDirectory string, messagePath string) error
+	// Index maps the path of every message in the mailbox directory to its Message-ID, which is empty for messages
+	// without one
+	Index(absoluteMailboxDirectory string) (map[string]string, error)
+	// Addresses returns the addresses in the address fields of every message in the mailbox directory. Fields that can
+	// not be parsed are skipped
+	Addresses(absoluteMailboxDirectory string) ([]string, error)
+	// Walk calls fn with every message in the mailbox directory as an RFC 5322 message, together with its local flags
+	Walk(absoluteMailboxDirectory string, fn func(raw []byte, flagList []string) error) error
+}
+
+// Names of the available storage backends
+const (
+	StorageFiles   = "files"
+	StorageMaildir = "maildir"
+)
+
+var (
+	errUnknownStorage  = errors.New("unknown storage")
+	errStorageMismatch = errors.New("storage mismatch")
+)
+
+func newStorage(fs *afero.Afero, name string, opts options) (storage, error) {
+	switch name {
+	case StorageFiles:
+		return fileStorage{
+			fs:       fs,
+			namer:    opts.namer,
+			format:   opts.messageFormat,
+			keepHTML: opts.keepHTML,
+			keepRaw:  opts.keepRaw,
+		}, nil
+	case StorageMaildir:
+		return maildirStorage{fs: fs}, nil
+	default:
+		return nil, fmt.Errorf("%w %q, expected %s or %s", errUnknownStorage, name, StorageFiles, StorageMaildir)
+	}
+}
+
+// fileStorage keeps messages as message files with a header block, named by a template
+type fileStorage struct {
+	fs       *afero.Afero
+	namer    fsconv.Namer
+	format   frontmatter.Format
+	keepHTML bool
+	keepRaw  bool
+}
+
+func (s fileStorage) Write(absoluteMailboxDirectory string, message email.Message) (string, error) {
+	fsconvMessage := emailMessageToFsConvMessage(message)
+
+	if !s.keepHTML {
+		fsconvMessage.HTML = ""
+	}
+
+	if !s.keepRaw {
+		fsconvMessage.Raw = nil
+	}
+
+	return fsconv.WriteMessageToDirectory(s.fs, absoluteMailboxDirectory, s.namer, s.format, fsconvMessage)
+}
+
+func (s fileStorage) Exists(absoluteMailboxDirectory string, messagePath string) (bool, error) {
+	return s.fs.Exists(path.Join(absoluteMailboxDirectory, messagePath))
+}
+
+func (s fileStorage) ReadFlags(absoluteMailboxDirectory string, messagePath string) ([]string, error) {
+	return fsconv.ReadFlags(s.fs, path.Join(absoluteMailboxDirectory, messagePath))
+}
+
+func (s fileStorage) UpdateFlags(absoluteMailboxDirectory string, messagePath string, flagList []string) error {
+	return fsconv.UpdateFlags(s.fs, path.Join(absoluteMailboxDirectory, messagePath), flagList)
+}
+
+func (s fileStorage) Forget(absoluteMailboxDirectory string, messagePath string) error {
+	return fsconv.RemoveSidecars(s.fs, absoluteMailboxDirectory, messagePath)
+}
+
+func (s fileStorage) Index(absoluteMailboxDirectory string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	files, err := s.fs.ReadDir(absoluteMailboxDirectory)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || fsconv.IsSidecar(file.Name()) {
+			continue
+		}
+
+		result[file.Name()], err = fsconv.ReadMessageID(s.fs, path.Join(absoluteMailboxDirectory, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading Message-ID of %s: %w", file.Name(), err)
+		}
+	}
+
+	return result, nil
+}
+
+func (s fileStorage) Addresses(absoluteMailboxDirectory string) ([]string, error) {
+	result := make([]string, 0)
+
+	files, err := s.fs.ReadDir(absoluteMailboxDirectory)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || fsconv.IsSidecar(file.Name()) {
+			continue
+		}
+
+		list, err := fsconv.ReadAddresses(s.fs, path.Join(absoluteMailboxDirectory, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading addresses of %s: %w", file.Name(), err)
+		}
+
+		result = append(result, list...)
+	}
+
+	return result, nil
+}
+
+// Walk passes the raw message stored next to a message file when there is one. Other message files are rendered the
+// way they would be sent, which loses the MIME structure and headers of the original message
+func (s fileStorage) Walk(absoluteMailboxDirectory string, fn func(raw []byte, flagList []string) error) error {
+	files, err := s.fs.ReadDir(absoluteMailboxDirectory)
+	if err != nil {
+		return fmt.Errorf("listing: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || fsconv.IsSidecar(file.Name()) {
+			continue
+		}
+
+		message, err := fsconv.ReadMessage(s.fs, absoluteMailboxDirectory, file.Name())
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", file.Name(), err)
+		}
+
+		raw, err := s.fs.ReadFile(path.Join(absoluteMailboxDirectory, fsconv.EMLSidecar(file.Name())))
+		if errors.Is(err, os.ErrNotExist) {
+			raw, err = email.Render(fsConvMessageToEmailMessage(message))
+		}
+
+		if err != nil {
+			return fmt.Errorf("acquiring raw message of %s: %w", file.Name(), err)
+		}
+
+		err = fn(raw, message.Flags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maildirStorage keeps messages as they were received in a Maildir, identified by the unique part of their filename
+type maildirStorage struct {
+	fs *afero.Afero
+}
+
+func (s maildirStorage) Write(absoluteMailboxDirectory string, message email.Message) (string, error) {
+	return maildir.Deliver(s.fs, absoluteMailboxDirectory, message.Raw, flags.FromIMAP(message.Flags))
+}
+
+func (s maildirStorage) Exists(absoluteMailboxDirectory string, messagePath string) (bool, error) {
+	relativePath, err := maildir.Find(s.fs, absoluteMailboxDirectory, messagePath)
+	if err != nil {
+		return false, err
+	}
+
+	return relativePath != "", nil
+}
+
+func (s maildirStorage) ReadFlags(absoluteMailboxDirectory string, messagePath string) ([]string, error) {
+	return maildir.ReadFlags(s.fs, absoluteMailboxDirectory, messagePath)
+}
+
+func (s maildirStorage) UpdateFlags(absoluteMailboxDirectory string, messagePath string, flagList []string) error {
+	return maildir.SetFlags(s.fs, absoluteMailboxDirectory, messagePath, flagList)
+}
+
+// Forget does nothing, as a Maildir message consists of a single file
+func (s maildirStorage) Forget(string, string) error {
+	return nil
+}
+
+func (s maildirStorage) Index(absoluteMailboxDirectory string) (map[string]string, error) {
+	keys, err := maildir.Keys(s.fs, absoluteMailboxDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	result := make(map[string]string, len(keys))
+
+	for _, key := range keys {
+		raw, err := maildir.Read(s.fs, absoluteMailboxDirectory, key)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", key, err)
+		}
+
+		result[key] = readMessageID(raw)
+	}
+
+	return result, nil
+}
+
+func (s maildirStorage) Addresses(absoluteMailboxDirectory string) ([]string, error) {
+	keys, err := maildir.Keys(s.fs, absoluteMailboxDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	result := make([]string, 0)
+
+	for _, key := range keys {
+		raw, err := maildir.Read(s.fs, absoluteMailboxDirectory, key)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", key, err)
+		}
+
+		result = append(result, readAddresses(raw)...)
+	}
+
+	return result, nil
+}
+
+func (s maildirStorage) Walk(absoluteMailboxDirectory string, fn func(raw []byte, flagList []string) error) error {
+	keys, err := maildir.Keys(s.fs, absoluteMailboxDirectory)
+	if err != nil {
+		return fmt.Errorf("listing: %w", err)
+	}
+
+	for _, key := range keys {
+		raw, err := maildir.Read(s.fs, absoluteMailboxDirectory, key)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+
+		flagList, err := maildir.ReadFlags(s.fs, absoluteMailboxDirectory, key)
+		if err != nil {
+			return fmt.Errorf("reading flags of %s: %w", key, err)
+		}
+
+		err = fn(raw, flagList)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMessageID knows how to read the Message-ID of a raw message. Messages without a readable header have none
+func readMessageID(raw []byte) string {
+	message, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+
+	return messageid.Parse(message.Header.Get("Message-Id"))
+}
+
+// addressFields contains the header fields of a raw message holding addresses
+var addressFields = []string{"From", "To", "Cc", "Bcc", "Reply-To"}
+
+// readAddresses knows how to read the addresses in the header of a raw message. Fields that can not be parsed are
+// skipped
+func readAddresses(raw []byte) []string {
+	message, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+
+	result := make([]string, 0)
+
+	for _, field := range addressFields {
+		list, err := message.Header.AddressList(field)
+		if err != nil {
+			continue
+		}
+
+		for _, item := range list {
+			result = append(result, addresses.Format(item.Name, item.Address))
+		}
+	}
+
+	return result
+}
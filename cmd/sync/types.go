@@ -1,7 +1,37 @@
 package sync
 
+import (
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+)
+
 type logger interface {
 	Debug(args ...interface{})
 	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
 	Warn(args ...interface{})
 }
+
+type options struct {
+	absoluteWorkDirectory   string
+	absoluteOutboxDirectory string
+	absoluteSentDirectory   string
+	filter                  mailboxFilter
+	maxDeletions            int
+	expungeAll              bool
+	defaultFormat           string
+	keepHTML                bool
+	keepRaw                 bool
+	namer                   fsconv.Namer
+	messageFormat           frontmatter.Format
+	storageName             string
+	storage                 storage
+}
+
+const (
+	inboxMailboxName    = "INBOX"
+	outboxDirectoryName = "outbox"
+	sentDirectoryName   = "sent"
+
+	defaultDirectoryPermissions = 0o700
+)
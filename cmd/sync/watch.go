@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/accounts"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// outboxSettleDelay defines how long a file in the outbox must stay unchanged before it is considered written
+	outboxSettleDelay = 500 * time.Millisecond
+	// reconnectDelay defines how long to wait before reopening a failed IDLE connection
+	reconnectDelay = 30 * time.Second
+)
+
+func WatchRunE(log logger, fs *afero.Afero, selection *accounts.Selection) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		opts, creds, err := prepare(log, fs, selection.Current)
+		if err != nil {
+			return fmt.Errorf("preparing: %w", err)
+		}
+
+		err = fs.MkdirAll(opts.absoluteOutboxDirectory, defaultDirectoryPermissions)
+		if err != nil {
+			return fmt.Errorf("creating outbox directory: %w", err)
+		}
+
+		err = syncInbox(log, fs, opts, creds)
+		if err != nil {
+			return fmt.Errorf("synchronizing inbox: %w", err)
+		}
+
+		sendOutbox(log, fs, opts, creds)
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("creating file watcher: %w", err)
+		}
+
+		defer func() {
+			_ = watcher.Close()
+		}()
+
+		err = watcher.Add(opts.absoluteOutboxDirectory)
+		if err != nil {
+			return fmt.Errorf("watching outbox directory: %w", err)
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+		defer signal.Stop(signals)
+
+		notify := make(chan struct{}, 1)
+		stop := make(chan struct{})
+		idleErrors := make(chan error, 1)
+
+		startIdle := func() {
+			go func() {
+				idleErrors <- email.WatchMailbox(log, creds, inboxMailboxName, notify, stop)
+			}()
+		}
+
+		startIdle()
+
+		var (
+			settled   <-chan time.Time
+			reconnect <-chan time.Time
+		)
+
+		log.Infof("Watching %s for changes", opts.absoluteWorkDirectory)
+
+		for {
+			select {
+			case <-signals:
+				close(stop)
+
+				if reconnect == nil {
+					<-idleErrors
+				}
+
+				return nil
+			case err := <-idleErrors:
+				log.Warn(fmt.Errorf("watching inbox, reconnecting in %s: %w", reconnectDelay, err).Error())
+
+				reconnect = time.After(reconnectDelay)
+			case <-reconnect:
+				reconnect = nil
+
+				err = syncInbox(log, fs, opts, creds)
+				if err != nil {
+					log.Warn(fmt.Errorf("synchronizing inbox: %w", err).Error())
+				}
+
+				startIdle()
+			case <-notify:
+				err = syncInbox(log, fs, opts, creds)
+				if err != nil {
+					log.Warn(fmt.Errorf("synchronizing inbox: %w", err).Error())
+				}
+			case event := <-watcher.Events:
+				if !isRelevantOutboxEvent(event) {
+					continue
+				}
+
+				settled = time.After(outboxSettleDelay)
+			case err := <-watcher.Errors:
+				log.Warn(fmt.Errorf("watching outbox: %w", err).Error())
+			case <-settled:
+				settled = nil
+
+				sendOutbox(log, fs, opts, creds)
+			}
+		}
+	}
+}
+
+func syncInbox(log logger, fs *afero.Afero, opts options, creds email.Credentials) error {
+	opts.filter.only = inboxMailboxName
+
+	return handleMailboxes(log, fs, opts, creds)
+}
+
+func sendOutbox(log logger, fs *afero.Afero, opts options, creds email.Credentials) {
+	err := handleOutbox(log, fs, opts.absoluteOutboxDirectory, opts.absoluteSentDirectory, opts.defaultFormat, creds)
+	if err != nil {
+		log.Warn(fmt.Errorf("handling outbox: %w", err).Error())
+	}
+}
+
+// isRelevantOutboxEvent filters out events caused by removing files and by editors' temporary files
+func isRelevantOutboxEvent(event fsnotify.Event) bool {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return false
+	}
+
+	name := filepath.Base(event.Name)
+
+	return !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, "~")
+}
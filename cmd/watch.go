@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/deifyed/fsmail/cmd/sync"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "keeps the directory synchronized with the server until interrupted",
+	Long: `Keeps an IMAP IDLE connection open and writes new mail into the inbox directory as it arrives.
+Files written to the outbox directory are sent as soon as they stop changing.`,
+	Args: cobra.ExactArgs(0),
+	RunE: sync.WatchRunE(log, fs, &selection),
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,263 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/deifyed/fsmail/pkg/email"
+	"github.com/deifyed/fsmail/pkg/encryptedfile"
+	"github.com/deifyed/fsmail/pkg/environment"
+	"github.com/deifyed/fsmail/pkg/keyring"
+	"github.com/deifyed/fsmail/pkg/oauth"
+	"github.com/deifyed/fsmail/pkg/passwordcommand"
+	"github.com/spf13/afero"
+)
+
+// Select knows how to pick the account called name among the configured accounts. defaults contains the top level
+// settings, with the work directory as Directory. Without configured accounts, defaults is the only account. Otherwise
+// an empty name selects the first configured account
+func Select(configured []Account, defaults Account, name string) (Selection, error) {
+	all, err := resolveAll(configured, defaults)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	if name == "" {
+		return Selection{Current: all[0], All: all}, nil
+	}
+
+	for _, account := range all {
+		if account.Name == name {
+			return Selection{Current: account, All: all}, nil
+		}
+	}
+
+	return Selection{}, fmt.Errorf("%w %q", errUnknownAccount, name)
+}
+
+// KeyringPrefix returns the prefix of the keyring entry holding the credentials of the account
+func (a Account) KeyringPrefix() string {
+	if a.Name == "" {
+		return legacyKeyringPrefix
+	}
+
+	return keyringPrefix + a.Name
+}
+
+// CredentialsStore knows how to open the store keeping the credentials of the account
+func (a Account) CredentialsStore(fs *afero.Afero) (credentials.CredentialsStore, error) {
+	switch a.CredentialStore {
+	case StoreKeyring, "":
+		return keyring.Client{Prefix: a.KeyringPrefix()}, nil
+	case StoreCommand:
+		return passwordcommand.Client{Command: a.PasswordCommand, Username: a.Username}, nil
+	case StoreEnvironment:
+		return environment.Client{Prefix: environment.Prefix(a.Name)}, nil
+	case StoreFile:
+		// The default location is only looked up when needed, as it is unknown in environments without a home
+		// directory, where the path is configured instead
+		credentialsFile := a.CredentialsFile
+		if credentialsFile == "" {
+			var err error
+
+			credentialsFile, err = encryptedfile.DefaultPath()
+			if err != nil {
+				return nil, fmt.Errorf("locating credentials file: %w", err)
+			}
+		}
+
+		return encryptedfile.Client{
+			Fs:     fs,
+			Path:   credentialsFile,
+			Prefix: a.KeyringPrefix(),
+			// Every secret is read from the file on its own, and the passphrase should only be prompted for once
+			Passphrase: once(encryptedfile.ReadPassphrase),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w %q, expected %s, %s, %s or %s",
+			errUnknownStore, a.CredentialStore, StoreKeyring, StoreCommand, StoreEnvironment, StoreFile)
+	}
+}
+
+// ReadOnlyCredentials knows if the credentials of the account are managed outside of fsmail, making storing them fail
+func (a Account) ReadOnlyCredentials() bool {
+	return a.CredentialStore == StoreCommand || a.CredentialStore == StoreEnvironment
+}
+
+// Credentials knows how to read the credentials of the account from its store
+func (a Account) Credentials(fs *afero.Afero) (email.Credentials, error) {
+	store, err := a.CredentialsStore(fs)
+	if err != nil {
+		return email.Credentials{}, fmt.Errorf("opening credentials store: %w", err)
+	}
+
+	secrets := credentials.Credentials{}
+
+	secrets.Username, err = store.Get(credentials.CredentialsSecretName, credentials.UsernameKey)
+	if err != nil {
+		return email.Credentials{}, fmt.Errorf("retrieving username: %w", err)
+	}
+
+	if a.OAuth2.Enabled() {
+		secrets.RefreshToken, err = store.Get(credentials.CredentialsSecretName, credentials.RefreshTokenKey)
+		if err != nil {
+			return email.Credentials{}, fmt.Errorf("retrieving refresh token: %w", err)
+		}
+	} else {
+		secrets.Password, err = store.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+		if err != nil {
+			return email.Credentials{}, fmt.Errorf("retrieving password: %w", err)
+		}
+	}
+
+	// A refresh token replaced by the provider is kept, as the stored one stops working eventually
+	var rotated func(string) error
+
+	if !a.ReadOnlyCredentials() {
+		rotated = func(refreshToken string) error {
+			stored, err := credentials.Read(store)
+			if err != nil {
+				return fmt.Errorf("reading credentials: %w", err)
+			}
+
+			stored.RefreshToken = refreshToken
+
+			return credentials.Write(store, stored)
+		}
+	}
+
+	return a.EmailCredentials(secrets, rotated)
+}
+
+// EmailCredentials knows how to combine the secrets of the account with its server addresses. Accounts using OAuth 2.0
+// get access tokens refreshed from the refresh token instead of a password. rotated is called with the new refresh
+// token when the provider replaces it, unless it is nil
+func (a Account) EmailCredentials(secrets credentials.Credentials, rotated func(string) error) (email.Credentials, error) {
+	creds := email.Credentials{
+		IMAPServerAddress: a.IMAPServerAddress,
+		SMTPServerAddress: a.SMTPServerAddress,
+		Username:          secrets.Username,
+		Password:          secrets.Password,
+	}
+
+	if !a.OAuth2.Enabled() {
+		return creds, nil
+	}
+
+	oauthConfig, err := a.OAuth2.Resolve()
+	if err != nil {
+		return email.Credentials{}, fmt.Errorf("preparing OAuth 2.0: %w", err)
+	}
+
+	creds.Password = ""
+	creds.Mechanism = oauthConfig.Mechanism
+
+	creds.Token, err = oauth.TokenSource(context.Background(), oauthConfig, secrets.RefreshToken, rotated)
+	if err != nil {
+		return email.Credentials{}, fmt.Errorf("preparing access tokens: %w", err)
+	}
+
+	return creds, nil
+}
+
+// DisplayName names the account in messages. The unnamed account is called default
+func (a Account) DisplayName() string {
+	if a.Name == "" {
+		return "default"
+	}
+
+	return a.Name
+}
+
+// resolveAll validates the configured accounts and fills in their missing settings from defaults
+func resolveAll(configured []Account, defaults Account) ([]Account, error) {
+	defaults.Name = ""
+
+	if len(configured) == 0 {
+		return []Account{defaults}, nil
+	}
+
+	seen := make(map[string]bool)
+	all := make([]Account, len(configured))
+
+	for index, account := range configured {
+		if !validName.MatchString(account.Name) {
+			return nil, fmt.Errorf("account %d has name %q, expected letters, digits, ., _ or -: %w",
+				index+1, account.Name, errInvalidAccount)
+		}
+
+		if seen[account.Name] {
+			return nil, fmt.Errorf("%w %q", errDuplicateAccount, account.Name)
+		}
+
+		seen[account.Name] = true
+		all[index] = resolve(account, defaults)
+	}
+
+	return all, nil
+}
+
+func resolve(account Account, defaults Account) Account {
+	if account.IMAPServerAddress == "" {
+		account.IMAPServerAddress = defaults.IMAPServerAddress
+	}
+
+	if account.SMTPServerAddress == "" {
+		account.SMTPServerAddress = defaults.SMTPServerAddress
+	}
+
+	if account.CredentialStore == "" {
+		account.CredentialStore = defaults.CredentialStore
+	}
+
+	if account.PasswordCommand == "" {
+		account.PasswordCommand = defaults.PasswordCommand
+	}
+
+	if account.Username == "" {
+		account.Username = defaults.Username
+	}
+
+	if account.CredentialsFile == "" {
+		account.CredentialsFile = defaults.CredentialsFile
+	}
+
+	if !account.OAuth2.Enabled() {
+		account.OAuth2 = defaults.OAuth2
+	}
+
+	if account.From == "" {
+		account.From = defaults.From
+	}
+
+	// Accounts sharing a work directory would share their sync state, so each account gets its own by default
+	switch {
+	case account.Directory == "":
+		account.Directory = path.Join(defaults.Directory, account.Name)
+	case !filepath.IsAbs(account.Directory):
+		account.Directory = path.Join(defaults.Directory, account.Directory)
+	}
+
+	return account
+}
+
+// once wraps fn, calling it the first time only and returning the same result afterwards
+func once(fn func() (string, error)) func() (string, error) {
+	var (
+		called bool
+		value  string
+		err    error
+	)
+
+	return func() (string, error) {
+		if !called {
+			value, err = fn()
+			called = true
+		}
+
+		return value, err
+	}
+}
@@ -0,0 +1,254 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/deifyed/fsmail/pkg/environment"
+	"github.com/deifyed/fsmail/pkg/keyring"
+	"github.com/deifyed/fsmail/pkg/oauth"
+	"github.com/deifyed/fsmail/pkg/passwordcommand"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	defaults := Account{
+		IMAPServerAddress: "imap.example.com:993",
+		SMTPServerAddress: "smtp.example.com:465",
+		Directory:         "/mail",
+		CredentialStore:   StoreCommand,
+		PasswordCommand:   "pass show mail",
+		Username:          "jane",
+		From:              "jane@example.com",
+	}
+
+	testCases := []struct {
+		name           string
+		withConfigured []Account
+		withName       string
+		expectCurrent  Account
+		expectAll      int
+		expectErr      error
+	}{
+		{
+			name:          "Should use the top level settings without configured accounts",
+			expectCurrent: defaults,
+			expectAll:     1,
+		},
+		{
+			name: "Should select the first account without a name",
+			withConfigured: []Account{
+				{Name: "personal", IMAPServerAddress: "imap.personal.com:993"},
+				{Name: "work"},
+			},
+			expectCurrent: Account{
+				Name:              "personal",
+				IMAPServerAddress: "imap.personal.com:993",
+				SMTPServerAddress: "smtp.example.com:465",
+				Directory:         "/mail/personal",
+				CredentialStore:   StoreCommand,
+				PasswordCommand:   "pass show mail",
+				Username:          "jane",
+				From:              "jane@example.com",
+			},
+			expectAll: 2,
+		},
+		{
+			name: "Should resolve relative and keep absolute directories",
+			withConfigured: []Account{
+				{Name: "personal", Directory: "/home/jane/mail"},
+				{Name: "work", Directory: "job"},
+			},
+			withName: "work",
+			expectCurrent: Account{
+				Name:              "work",
+				IMAPServerAddress: "imap.example.com:993",
+				SMTPServerAddress: "smtp.example.com:465",
+				Directory:         "/mail/job",
+				CredentialStore:   StoreCommand,
+				PasswordCommand:   "pass show mail",
+				Username:          "jane",
+				From:              "jane@example.com",
+			},
+			expectAll: 2,
+		},
+		{
+			name: "Should keep the sender of an account",
+			withConfigured: []Account{
+				{Name: "work", From: "Jane Doe <jane@work.com>"},
+			},
+			expectCurrent: Account{
+				Name:              "work",
+				IMAPServerAddress: "imap.example.com:993",
+				SMTPServerAddress: "smtp.example.com:465",
+				Directory:         "/mail/work",
+				CredentialStore:   StoreCommand,
+				PasswordCommand:   "pass show mail",
+				Username:          "jane",
+				From:              "Jane Doe <jane@work.com>",
+			},
+			expectAll: 1,
+		},
+		{
+			name: "Should keep the username of an account and default the rest of its credentials",
+			withConfigured: []Account{
+				{Name: "work", Username: "jane.doe@work.com"},
+			},
+			expectCurrent: Account{
+				Name:              "work",
+				IMAPServerAddress: "imap.example.com:993",
+				SMTPServerAddress: "smtp.example.com:465",
+				Directory:         "/mail/work",
+				CredentialStore:   StoreCommand,
+				PasswordCommand:   "pass show mail",
+				Username:          "jane.doe@work.com",
+				From:              "jane@example.com",
+			},
+			expectAll: 1,
+		},
+		{
+			name: "Should keep the password command of an account",
+			withConfigured: []Account{
+				{Name: "work", PasswordCommand: "pass show work"},
+			},
+			expectCurrent: Account{
+				Name:              "work",
+				IMAPServerAddress: "imap.example.com:993",
+				SMTPServerAddress: "smtp.example.com:465",
+				Directory:         "/mail/work",
+				CredentialStore:   StoreCommand,
+				PasswordCommand:   "pass show work",
+				Username:          "jane",
+				From:              "jane@example.com",
+			},
+			expectAll: 1,
+		},
+		{
+			name:           "Should fail on unknown accounts",
+			withConfigured: []Account{{Name: "personal"}},
+			withName:       "work",
+			expectErr:      errUnknownAccount,
+		},
+		{
+			name:           "Should fail on duplicate accounts",
+			withConfigured: []Account{{Name: "work"}, {Name: "work"}},
+			expectErr:      errDuplicateAccount,
+		},
+		{
+			name:           "Should fail on names unsafe for directories",
+			withConfigured: []Account{{Name: "../work"}},
+			expectErr:      errInvalidAccount,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			selection, err := Select(tc.withConfigured, defaults, tc.withName)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCurrent, selection.Current)
+			assert.Len(t, selection.All, tc.expectAll)
+		})
+	}
+}
+
+func TestKeyringPrefix(t *testing.T) {
+	assert.Equal(t, "fssmtp", Account{}.KeyringPrefix())
+	assert.Equal(t, "fsmail-work", Account{Name: "work"}.KeyringPrefix())
+	assert.NotEqual(t, Account{Name: "work"}.KeyringPrefix(), Account{Name: "personal"}.KeyringPrefix())
+}
+
+func TestDisplayName(t *testing.T) {
+	assert.Equal(t, "default", Account{}.DisplayName())
+	assert.Equal(t, "work", Account{Name: "work"}.DisplayName())
+}
+
+func TestEmailCredentials(t *testing.T) {
+	account := Account{IMAPServerAddress: "imap.example.com:993", SMTPServerAddress: "smtp.example.com:465"}
+	secrets := credentials.Credentials{Username: "jane", Password: "secret", RefreshToken: "mock-refresh-token"}
+
+	creds, err := account.EmailCredentials(secrets, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "imap.example.com:993", creds.IMAPServerAddress)
+	assert.Equal(t, "secret", creds.Password)
+	assert.Nil(t, creds.Token)
+
+	account.OAuth2 = oauth.Config{Provider: oauth.ProviderGoogle, ClientID: "mock-client"}
+
+	creds, err = account.EmailCredentials(secrets, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", creds.Password)
+	assert.Equal(t, oauth.MechanismXOAUTH2, creds.Mechanism)
+	assert.NotNil(t, creds.Token)
+}
+
+func TestCredentialsStore(t *testing.T) {
+	testCases := []struct {
+		name           string
+		withStore      string
+		expectStore    interface{}
+		expectReadOnly bool
+		expectErr      error
+	}{
+		{
+			name:        "Should default to the keyring",
+			withStore:   "",
+			expectStore: keyring.Client{Prefix: "fsmail-work"},
+		},
+		{
+			name:           "Should run the password command",
+			withStore:      StoreCommand,
+			expectStore:    passwordcommand.Client{Command: "pass show mail/work", Username: "jane@work.com"},
+			expectReadOnly: true,
+		},
+		{
+			name:           "Should read variables named after the account",
+			withStore:      StoreEnvironment,
+			expectStore:    environment.Client{Prefix: "FSMAIL_WORK"},
+			expectReadOnly: true,
+		},
+		{
+			name:      "Should fail on unknown stores",
+			withStore: "vault",
+			expectErr: errUnknownStore,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			account := Account{
+				Name:            "work",
+				CredentialStore: tc.withStore,
+				PasswordCommand: "pass show mail/work",
+				Username:        "jane@work.com",
+			}
+
+			store, err := account.CredentialsStore(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStore, store)
+			assert.Equal(t, tc.expectReadOnly, account.ReadOnlyCredentials())
+		})
+	}
+}
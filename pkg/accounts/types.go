@@ -0,0 +1,66 @@
+package accounts
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/deifyed/fsmail/pkg/oauth"
+)
+
+// Account describes a named profile with its own credentials, servers and work directory
+type Account struct {
+	// Name identifies the account. Empty for the account configured by the top level settings
+	Name              string `mapstructure:"name"`
+	IMAPServerAddress string `mapstructure:"imapServerAddress"`
+	SMTPServerAddress string `mapstructure:"smtpServerAddress"`
+	// Directory is the work directory of the account. Relative paths are relative to the work directory
+	Directory string `mapstructure:"directory"`
+	// CredentialStore names where the credentials are kept. One of StoreKeyring, StoreCommand, StoreEnvironment or
+	// StoreFile
+	CredentialStore string `mapstructure:"credentialStore"`
+	// PasswordCommand prints the password when CredentialStore is StoreCommand
+	PasswordCommand string `mapstructure:"passwordCommand"`
+	// Username is used together with PasswordCommand
+	Username string `mapstructure:"username"`
+	// CredentialsFile is the path of the encrypted file when CredentialStore is StoreFile
+	CredentialsFile string `mapstructure:"credentialsFile"`
+	// OAuth2 configures authentication with OAuth 2.0 instead of a password
+	OAuth2 oauth.Config `mapstructure:"oauth2"`
+	// From is the sender of drafts, e.g. `Jane Doe <jane@example.com>`
+	From string `mapstructure:"from"`
+}
+
+// Selection contains every configured account and the one chosen to work with
+type Selection struct {
+	Current Account
+	All     []Account
+}
+
+// Names of the available credential stores
+const (
+	// StoreKeyring keeps credentials in the secret store of the operating system
+	StoreKeyring = "keyring"
+	// StoreCommand reads the password from the output of PasswordCommand
+	StoreCommand = "command"
+	// StoreEnvironment reads credentials from environment variables
+	StoreEnvironment = "environment"
+	// StoreFile keeps credentials in a file encrypted with a passphrase
+	StoreFile = "file"
+)
+
+const (
+	// legacyKeyringPrefix prefixes the keyring entry of the unnamed account, which is where credentials were stored
+	// before accounts had names
+	legacyKeyringPrefix = "fssmtp"
+	keyringPrefix       = "fsmail-"
+)
+
+// validName matches names that are safe to use in keyring entries and directory names
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var (
+	errUnknownAccount   = errors.New("unknown account")
+	errInvalidAccount   = errors.New("invalid account")
+	errDuplicateAccount = errors.New("duplicate account")
+	errUnknownStore     = errors.New("unknown credential store")
+)
@@ -0,0 +1,61 @@
+package addresses
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// ParseList knows how to parse a comma separated list of addresses, e.g. `Jane Doe <jane@example.com>, john@example.com`.
+// Every address is returned in the form produced by Format
+func ParseList(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return []string{}, nil
+	}
+
+	list, err := mail.ParseAddressList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", raw, err)
+	}
+
+	result := make([]string, len(list))
+
+	for index, item := range list {
+		result[index] = Format(item.Name, item.Address)
+	}
+
+	return result, nil
+}
+
+// Parse knows how to split a single address into display name and address
+func Parse(raw string) (string, string, error) {
+	item, err := mail.ParseAddress(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing %q: %w", raw, err)
+	}
+
+	return item.Name, item.Address, nil
+}
+
+// Format knows how to turn a display name and an address into a human readable address. The display name is quoted
+// when it contains characters that would otherwise break parsing
+func Format(name string, address string) string {
+	if name == "" {
+		return address
+	}
+
+	if strings.ContainsAny(name, specials) {
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+
+	return fmt.Sprintf("%s <%s>", name, address)
+}
+
+// Join knows how to turn a list of addresses into a comma separated list
+func Join(list []string) string {
+	return strings.Join(list, ", ")
+}
+
+// specials contains the characters RFC 5322 does not allow in an unquoted display name
+const specials = `()<>[]:;@\,."`
@@ -0,0 +1,61 @@
+package addresses
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withRaw     string
+		expectList  []string
+		expectError bool
+	}{
+		{
+			name:       "Should parse a single address",
+			withRaw:    "me@example.com",
+			expectList: []string{"me@example.com"},
+		},
+		{
+			name:       "Should keep display names",
+			withRaw:    "Jane Doe <jane@example.com>, john@example.com",
+			expectList: []string{"Jane Doe <jane@example.com>", "john@example.com"},
+		},
+		{
+			name:       "Should keep commas in quoted display names",
+			withRaw:    `"Doe, Jane" <jane@example.com>, =?utf-8?q?J=C3=B8rgen?= <jorgen@example.com>`,
+			expectList: []string{`"Doe, Jane" <jane@example.com>`, "Jørgen <jorgen@example.com>"},
+		},
+		{
+			name:       "Should return an empty list for empty input",
+			withRaw:    " ",
+			expectList: []string{},
+		},
+		{
+			name:        "Should fail on invalid addresses",
+			withRaw:     "not an address",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			list, err := ParseList(tc.withRaw)
+
+			if tc.expectError {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectList, list)
+		})
+	}
+}
@@ -10,4 +10,51 @@ const (
 	IMAPServerAddress = "imapServerAddress"
 	// SMTPServerAddress defines the address of the SMTP server in a host:port format.
 	SMTPServerAddress = "smtpServerAddress"
+
+	// Account defines the name of the account to work with. The first account in Accounts is used when empty.
+	Account = "account"
+	// Accounts defines named accounts, each with a name, imapServerAddress, smtpServerAddress and directory.
+	Accounts = "accounts"
+
+	// CredentialStore defines where credentials are kept. One of keyring, command, environment or file.
+	CredentialStore = "credentialStore"
+	// PasswordCommand defines a shell command printing the password, used by the command credential store.
+	PasswordCommand = "passwordCommand"
+	// Username defines the username used by the command credential store.
+	Username = "username"
+	// CredentialsFile defines the path of the encrypted credentials file used by the file credential store.
+	CredentialsFile = "credentialsFile"
+
+	// OAuth2 defines how to authenticate with OAuth 2.0 instead of a password, with provider, clientID,
+	// clientSecret, authURL, tokenURL, deviceAuthURL, scopes, flow and mechanism.
+	OAuth2 = "oauth2"
+
+	// From defines the sender address of drafts, e.g. `Jane Doe <jane@example.com>`.
+	From = "from"
+
+	// IncludeFolders defines glob patterns for the IMAP folders to synchronize. Every folder is included when empty.
+	IncludeFolders = "includeFolders"
+	// ExcludeFolders defines glob patterns for IMAP folders to skip. Takes precedence over IncludeFolders.
+	ExcludeFolders = "excludeFolders"
+	// MaxDeletions defines the maximum amount of locally deleted messages a single sync is allowed to delete on the
+	// server.
+	MaxDeletions = "maxDeletions"
+	// ExpungeAll defines whether deleting messages on servers without UIDPLUS may expunge every message marked as
+	// deleted in the mailbox, including messages marked by other clients.
+	ExpungeAll = "expungeAll"
+
+	// DefaultFormat defines the format of outbox files without a Format header. One of markdown, plain or html.
+	DefaultFormat = "defaultFormat"
+
+	// Storage defines how received messages are stored. Either files, for message files with a header block, or
+	// maildir.
+	Storage = "storage"
+	// KeepHTML defines whether the original HTML of received messages is stored next to the message file.
+	KeepHTML = "keepHTML"
+	// KeepRaw defines whether the original RFC 5322 message of received messages is stored next to the message file.
+	KeepRaw = "keepRaw"
+	// FilenameTemplate defines the Go template used to name received message files.
+	FilenameTemplate = "filenameTemplate"
+	// MessageFormat defines how the header block of received message files is written. One of headers or yaml.
+	MessageFormat = "messageFormat"
 )
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"html/template"
 	"io"
+	"net/mail"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/deifyed/fsmail/pkg/frontmatter"
 	"github.com/sebdah/goldie/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,11 +25,173 @@ func TestToMessage(t *testing.T) {
 			withContent: newEmailContent(t, "me@example.com", "you@example.com", "testing", "such long mock body"),
 			expectMessage: Message{
 				From:    "me@example.com",
-				To:      "you@example.com",
+				To:      []string{"you@example.com"},
 				Subject: "testing",
 				Body:    "such long mock body",
 			},
 		},
+		{
+			name: "Should successfully convert a message with attachments",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: invoices
+Attach: invoice.pdf, receipts/receipt.png
+Attach: ../contract.pdf
+---
+
+see attached
+`),
+			expectMessage: Message{
+				From:        "me@example.com",
+				To:          []string{"you@example.com"},
+				Subject:     "invoices",
+				Body:        "see attached",
+				Attachments: []string{"invoice.pdf", "receipts/receipt.png", "../contract.pdf"},
+			},
+		},
+		{
+			name: "Should successfully convert a message with a format",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: formatted
+Content-Type: text/markdown
+---
+
+*hi*
+`),
+			expectMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "formatted",
+				Format:  FormatMarkdown,
+				Body:    "*hi*",
+			},
+		},
+		{
+			name: "Should successfully convert a message with a content type copied from a received message",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: formatted
+Content-Type: text/plain; charset=UTF-8
+---
+
+hi
+`),
+			expectMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "formatted",
+				Format:  FormatPlain,
+				Body:    "hi",
+			},
+		},
+		{
+			name: "Should successfully convert a message with multiple recipients",
+			withContent: strings.NewReader(`---
+To: Jane Doe <jane@example.com>, john@example.com
+Cc: "Doe, Jim" <jim@example.com>
+Bcc: boss@example.com
+Reply-To: Team <team@example.com>
+From: Me <me@example.com>
+Subject: meeting
+---
+
+see you there
+`),
+			expectMessage: Message{
+				From:    "Me <me@example.com>",
+				To:      []string{"Jane Doe <jane@example.com>", "john@example.com"},
+				Cc:      []string{`"Doe, Jim" <jim@example.com>`},
+				Bcc:     []string{"boss@example.com"},
+				ReplyTo: []string{"Team <team@example.com>"},
+				Subject: "meeting",
+				Body:    "see you there",
+			},
+		},
+		{
+			name: "Should successfully convert a reply",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: Re: meeting
+Date: Mon, 03 Oct 2022 12:00:00 +0200
+Message-ID: <third@example.com>
+In-Reply-To: <second@example.com>
+References: <first@example.com> <second@example.com>
+---
+
+sounds good
+`),
+			expectMessage: Message{
+				From:       "me@example.com",
+				To:         []string{"you@example.com"},
+				Subject:    "Re: meeting",
+				Date:       mustParseDate(t, "Mon, 03 Oct 2022 12:00:00 +0200"),
+				MessageID:  "third@example.com",
+				InReplyTo:  "second@example.com",
+				References: []string{"first@example.com", "second@example.com"},
+				Body:       "sounds good",
+			},
+		},
+		{
+			name: "Should keep empty bodies empty",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: no body
+---
+`),
+			expectMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "no body",
+			},
+		},
+		{
+			name: "Should keep bracketed and braced subjects",
+			withContent: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: [SPAM] {draft} offer
+---
+
+buy now
+`),
+			expectMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "[SPAM] {draft} offer",
+				Body:    "buy now",
+			},
+		},
+		{
+			name: "Should successfully convert a message with YAML front matter",
+			withContent: strings.NewReader(`---
+from: me@example.com
+to:
+  - Jane Doe <jane@example.com>
+  - john@example.com
+subject: 'Re: meeting'
+attach: [invoice, final.pdf]
+tags: [work]
+headers:
+  X-Priority: 1
+---
+
+sounds good
+`),
+			expectMessage: Message{
+				From:        "me@example.com",
+				To:          []string{"Jane Doe <jane@example.com>", "john@example.com"},
+				Subject:     "Re: meeting",
+				Attachments: []string{"invoice", "final.pdf"},
+				Headers:     []frontmatter.Header{{Key: "X-Priority", Value: "1"}},
+				Body:        "sounds good",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -42,6 +208,49 @@ func TestToMessage(t *testing.T) {
 	}
 }
 
+func TestParseFormat(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withRaw      string
+		expectFormat string
+		expectErr    error
+	}{
+		{name: "Should accept short names", withRaw: "md", expectFormat: FormatMarkdown},
+		{name: "Should accept media types", withRaw: "text/html", expectFormat: FormatHTML},
+		{
+			name:         "Should accept media types with parameters",
+			withRaw:      "text/markdown; charset=utf-8",
+			expectFormat: FormatMarkdown,
+		},
+		{
+			name:         "Should accept media types in any case with parameters",
+			withRaw:      " Text/Plain; charset=UTF-8; format=flowed",
+			expectFormat: FormatPlain,
+		},
+		{name: "Should fail on unknown media types", withRaw: "image/png; name=cat.png", expectErr: errUnknownFormat},
+		{name: "Should fail on empty values", withRaw: "", expectErr: errUnknownFormat},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			format, err := parseFormat(tc.withRaw)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectFormat, format)
+		})
+	}
+}
+
 func TestToReader(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -51,11 +260,22 @@ func TestToReader(t *testing.T) {
 			name: "Should convert a plain message",
 			with: Message{
 				From:    "me@example.com",
-				To:      "you@example.com",
+				To:      []string{"you@example.com"},
 				Subject: "testing",
 				Body:    "such long mock body",
 			},
 		},
+		{
+			name: "Should convert a reply",
+			with: Message{
+				From:       "me@example.com",
+				To:         []string{"you@example.com"},
+				Subject:    "Re: meeting",
+				InReplyTo:  "second@example.com",
+				References: []string{"first@example.com", "second@example.com"},
+				Body:       "sounds good",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -76,6 +296,15 @@ func TestToReader(t *testing.T) {
 	}
 }
 
+func mustParseDate(t *testing.T, raw string) time.Time {
+	t.Helper()
+
+	date, err := mail.ParseDate(raw)
+	assert.NoError(t, err)
+
+	return date
+}
+
 const emailTemplate = `---
 To: {{ .To }}
 From: {{ .From }}
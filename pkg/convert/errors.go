@@ -2,4 +2,4 @@ package convert
 
 import "errors"
 
-var errMissingBody = errors.New("missing body")
+var errUnknownFormat = errors.New("unknown format")
@@ -1,81 +1,100 @@
 package convert
 
 import (
-	"bytes"
 	"fmt"
-	"io"
+	"mime"
+	"strconv"
 	"strings"
+
+	"github.com/deifyed/fsmail/pkg/frontmatter"
 )
 
-func extractHeader(msg *Message, content io.Reader) error {
-	raw, err := io.ReadAll(content)
+func documentToMessage(doc frontmatter.Document) (Message, error) {
+	envelope, err := doc.Envelope()
 	if err != nil {
-		return fmt.Errorf("reading content: %w", err)
+		return Message{}, err
 	}
 
-	lines := bytes.Split(raw, []byte("\n"))
-	headerDividerCount := 0
+	msg := Message{
+		From:        envelope.From,
+		To:          envelope.To,
+		Cc:          envelope.Cc,
+		Bcc:         envelope.Bcc,
+		ReplyTo:     envelope.ReplyTo,
+		Subject:     envelope.Subject,
+		Date:        envelope.Date,
+		MessageID:   envelope.MessageID,
+		InReplyTo:   envelope.InReplyTo,
+		References:  envelope.References,
+		Attachments: doc.List(frontmatter.AttachKey),
+		Body:        strings.TrimSpace(doc.Body),
+		Headers:     envelope.Headers,
+	}
 
-	for _, line := range lines {
-		if headerDividerCount == 2 {
-			break
+	for _, key := range []string{frontmatter.FormatKey, frontmatter.ContentTypeKey} {
+		if value := doc.Get(key); value != "" {
+			msg.Format, err = parseFormat(value)
+			if err != nil {
+				return Message{}, fmt.Errorf("parsing %s: %w", key, err)
+			}
 		}
+	}
 
-		switch {
-		case bytes.HasPrefix(line, []byte("---")):
-			headerDividerCount++
-		case bytes.HasPrefix(line, []byte("From:")):
-			msg.From = string(bytes.TrimPrefix(line, []byte("From: ")))
-		case bytes.HasPrefix(line, []byte("To:")):
-			msg.To = string(bytes.TrimPrefix(line, []byte("To: ")))
-		case bytes.HasPrefix(line, []byte("Subject:")):
-			msg.Subject = string(bytes.TrimPrefix(line, []byte("Subject: ")))
-		default:
-			return fmt.Errorf("invalid header line: %s", line)
+	if value := doc.Get(frontmatter.DraftKey); value != "" {
+		msg.Draft, err = strconv.ParseBool(value)
+		if err != nil {
+			return Message{}, fmt.Errorf("parsing %s: %w", frontmatter.DraftKey, err)
 		}
 	}
 
-	return nil
+	return msg, nil
 }
 
-func extractBody(msg *Message, content io.Reader) error {
-	raw, err := io.ReadAll(content)
-	if err != nil {
-		return fmt.Errorf("reading content: %w", err)
+func messageToDocument(msg Message) frontmatter.Document {
+	doc := frontmatter.Document{Format: frontmatter.FormatHeaders, Body: msg.Body + "\n"}
+
+	doc.AddEnvelope(frontmatter.Envelope{
+		From:       msg.From,
+		To:         msg.To,
+		Cc:         msg.Cc,
+		Bcc:        msg.Bcc,
+		ReplyTo:    msg.ReplyTo,
+		Subject:    msg.Subject,
+		Date:       msg.Date,
+		MessageID:  msg.MessageID,
+		InReplyTo:  msg.InReplyTo,
+		References: msg.References,
+		Headers:    msg.Headers,
+	})
+
+	doc.AddNonEmpty(frontmatter.FormatKey, msg.Format)
+
+	for _, attachment := range msg.Attachments {
+		doc.Add(frontmatter.AttachKey, attachment)
 	}
 
-	lines := bytes.Split(raw, []byte("\n"))
-	headerDividerCount := 0
-
-	builder := strings.Builder{}
-
-	for _, line := range lines {
-		if headerDividerCount == 2 {
-			builder.Write(line)
-			builder.WriteString("\n")
-
-			continue
-		}
-
-		if bytes.HasPrefix(line, []byte("---")) {
-			headerDividerCount++
-		}
-	}
-
-	switch {
-	case headerDividerCount == 0:
-		return fmt.Errorf("no header divider found")
-	case headerDividerCount == 1:
-		return fmt.Errorf("only one header divider found")
-	case builder.Len() == 0:
-		return errMissingBody
+	if msg.Draft {
+		doc.Add(frontmatter.DraftKey, strconv.FormatBool(msg.Draft))
 	}
 
-	result := builder.String()
-	result = strings.TrimPrefix(result, "\n")
-	result = strings.TrimSpace(result)
-
-	msg.Body = result
+	return doc
+}
 
-	return nil
+// parseFormat accepts both the short format names and their media types. Parameters such as the charset are ignored, as
+// outgoing messages are always encoded as UTF-8
+func parseFormat(raw string) (string, error) {
+	// Media types with invalid parameters are still returned, and the parameters are of no interest
+	mediaType, _, _ := mime.ParseMediaType(raw)
+
+	switch mediaType {
+	case FormatMarkdown, "md", "text/markdown":
+		return FormatMarkdown, nil
+	case FormatPlain, "text", "text/plain":
+		return FormatPlain, nil
+	case FormatHTML, "text/html":
+		return FormatHTML, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected one of %s, %s or %s: %w",
+			strings.TrimSpace(raw), FormatMarkdown, FormatPlain, FormatHTML, errUnknownFormat)
+	}
 }
@@ -1,10 +1,40 @@
 package convert
 
+import (
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+)
+
 type Message struct {
 	From    string
-	To      string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo []string
 	Subject string
-	Body    string
+	Date    time.Time
+	// MessageID, InReplyTo and References contain message IDs without angle brackets
+	MessageID  string
+	InReplyTo  string
+	References []string
+	// Format describes how the body is written. One of FormatMarkdown, FormatPlain or FormatHTML, or empty when the
+	// file does not specify it
+	Format string
+	Body   string
+	// Attachments contains the paths of files to attach, relative to the message file
+	Attachments []string
+	// Draft marks a message that is still being written and must not be sent yet
+	Draft bool
+	// Headers contains additional fields of the header block, sent as they are
+	Headers []frontmatter.Header
 }
 
-const divider = "---"
+const (
+	// FormatMarkdown indicates a Markdown body
+	FormatMarkdown = "markdown"
+	// FormatPlain indicates a plain text body
+	FormatPlain = "plain"
+	// FormatHTML indicates an HTML body
+	FormatHTML = "html"
+)
@@ -0,0 +1,41 @@
+package credentials
+
+import "fmt"
+
+// Read knows how to read every stored credential, so a single one can be changed without losing the others
+func Read(store CredentialsStore) (Credentials, error) {
+	creds := Credentials{}
+
+	for key, target := range map[string]*string{
+		SMTPServerAddressKey: &creds.SMTPServerAddress,
+		IMAPServerAddressKey: &creds.IMAPServerAddress,
+		UsernameKey:          &creds.Username,
+		PasswordKey:          &creds.Password,
+		RefreshTokenKey:      &creds.RefreshToken,
+	} {
+		value, err := store.Get(CredentialsSecretName, key)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("retrieving %s: %w", key, err)
+		}
+
+		*target = value
+	}
+
+	return creds, nil
+}
+
+// Write knows how to store every credential, replacing the ones stored before
+func Write(store CredentialsStore, creds Credentials) error {
+	secrets := map[string]string{
+		SMTPServerAddressKey: creds.SMTPServerAddress,
+		IMAPServerAddressKey: creds.IMAPServerAddress,
+		UsernameKey:          creds.Username,
+		PasswordKey:          creds.Password,
+	}
+
+	if creds.RefreshToken != "" {
+		secrets[RefreshTokenKey] = creds.RefreshToken
+	}
+
+	return store.Put(CredentialsSecretName, secrets)
+}
@@ -1,11 +1,15 @@
 package credentials
 
+import "errors"
+
 const (
 	CredentialsSecretName = "credentials"
 	SMTPServerAddressKey  = "smtp-server-address"
 	IMAPServerAddressKey  = "imap-server-address"
 	UsernameKey           = "username"
 	PasswordKey           = "password"
+	// RefreshTokenKey identifies the OAuth 2.0 refresh token of accounts authenticating with OAuth 2.0
+	RefreshTokenKey = "refresh-token"
 )
 
 type Credentials struct {
@@ -13,9 +17,17 @@ type Credentials struct {
 	IMAPServerAddress string
 	Username          string
 	Password          string
+	RefreshToken      string
 }
 
 type CredentialsStore interface {
 	Put(string, map[string]string) error
 	Get(string, string) (string, error)
+	Delete(string) error
 }
+
+// ErrReadOnly is returned by stores reading credentials that are managed elsewhere, such as by a password manager
+var ErrReadOnly = errors.New("read only credentials store")
+
+// ErrNotFound is returned when no credentials are stored under a name
+var ErrNotFound = errors.New("credentials not found")
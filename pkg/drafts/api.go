@@ -0,0 +1,209 @@
+package drafts
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/convert"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/spf13/afero"
+)
+
+// Reply knows how to create a draft answering original. The draft is addressed to the sender, or to everyone involved
+// when all is true. The address the draft is sent from is never among the recipients
+func Reply(original fsconv.Message, from string, all bool) (convert.Message, error) {
+	body, err := io.ReadAll(original.Body)
+	if err != nil {
+		return convert.Message{}, fmt.Errorf("reading body: %w", err)
+	}
+
+	to, cc := replyRecipients(original, from, all)
+
+	return convert.Message{
+		From:       from,
+		To:         to,
+		Cc:         cc,
+		Subject:    withPrefix(original.Subject, replyPrefix, replyPrefixes),
+		InReplyTo:  original.MessageID,
+		References: references(original),
+		Body:       fmt.Sprintf("\n%s\n%s", attribution(original), quote(string(body))),
+		Draft:      true,
+	}, nil
+}
+
+// Forward knows how to create a draft forwarding original. The recipients are left for the user to fill in. The
+// attachments of original are not part of the draft, they are passed to Write
+func Forward(original fsconv.Message, from string) (convert.Message, error) {
+	body, err := io.ReadAll(original.Body)
+	if err != nil {
+		return convert.Message{}, fmt.Errorf("reading body: %w", err)
+	}
+
+	return convert.Message{
+		From:       from,
+		To:         []string{},
+		Subject:    withPrefix(original.Subject, forwardPrefix, forwardPrefixes),
+		References: references(original),
+		Body:       fmt.Sprintf("\n%s\n\n%s", forwardedHeader(original), body),
+		Draft:      true,
+	}, nil
+}
+
+// Write knows how to write a draft into targetDir, together with the attachments it should carry. It returns the name
+// of the draft file
+func Write(fs *afero.Afero, targetDir string, draft convert.Message, attachments []fsconv.Attachment) (string, error) {
+	err := fs.MkdirAll(targetDir, defaultDirectoryPermissions)
+	if err != nil {
+		return "", fmt.Errorf("creating directory: %w", err)
+	}
+
+	namer, err := fsconv.NewNamer(fsconv.DefaultFilenameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("preparing namer: %w", err)
+	}
+
+	filename, err := namer.Name(fs, targetDir, fsconv.Message{Subject: draft.Subject})
+	if err != nil {
+		return "", fmt.Errorf("naming draft: %w", err)
+	}
+
+	attachmentPaths, err := fsconv.WriteAttachments(fs, targetDir, filename, attachments)
+	if err != nil {
+		return "", fmt.Errorf("writing attachments: %w", err)
+	}
+
+	draft.Attachments = append(draft.Attachments, attachmentPaths...)
+
+	err = fs.WriteReader(path.Join(targetDir, filename), convert.ToReader(draft))
+	if err != nil {
+		return "", fmt.Errorf("writing draft: %w", err)
+	}
+
+	return filename, nil
+}
+
+// replyRecipients figures out who a reply goes to. Replying to a message sent from the address itself, e.g. from the
+// sent directory, goes to the original recipients instead
+func replyRecipients(original fsconv.Message, from string, all bool) ([]string, []string) {
+	seen := make(map[string]bool)
+
+	if address := bareAddress(from); address != "" {
+		seen[address] = true
+	}
+
+	sender := original.ReplyTo
+	if len(sender) == 0 && original.From != "" {
+		sender = []string{original.From}
+	}
+
+	to := unique(seen, sender)
+	if len(to) == 0 {
+		to = unique(seen, original.To)
+	}
+
+	cc := []string{}
+
+	if all {
+		cc = unique(seen, append(append([]string{}, original.To...), original.Cc...))
+	}
+
+	return to, cc
+}
+
+// unique returns the addresses in list that are not yet in seen, and adds them to seen
+func unique(seen map[string]bool, list []string) []string {
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		address := bareAddress(item)
+
+		if seen[address] {
+			continue
+		}
+
+		seen[address] = true
+
+		result = append(result, item)
+	}
+
+	return result
+}
+
+func bareAddress(raw string) string {
+	_, address, err := addresses.Parse(raw)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(raw))
+	}
+
+	return strings.ToLower(address)
+}
+
+// withPrefix prepends prefix to subject, replacing any prefixes of the same kind already present
+func withPrefix(subject string, prefix string, known []string) string {
+	subject = strings.TrimSpace(subject)
+
+	for trimmed := true; trimmed; {
+		trimmed = false
+
+		for _, item := range known {
+			if len(subject) >= len(item) && strings.EqualFold(subject[:len(item)], item) {
+				subject = strings.TrimSpace(subject[len(item):])
+				trimmed = true
+			}
+		}
+	}
+
+	return strings.TrimSpace(prefix + " " + subject)
+}
+
+func references(original fsconv.Message) []string {
+	result := append([]string{}, original.References...)
+
+	if original.MessageID != "" {
+		result = append(result, original.MessageID)
+	}
+
+	return result
+}
+
+func attribution(original fsconv.Message) string {
+	if original.Date.IsZero() {
+		return fmt.Sprintf("%s wrote:", original.From)
+	}
+
+	return fmt.Sprintf("On %s, %s wrote:", original.Date.Format(time.RFC1123Z), original.From)
+}
+
+func quote(body string) string {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+
+	for index, line := range lines {
+		if line == "" {
+			lines[index] = ">"
+		} else {
+			lines[index] = "> " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func forwardedHeader(original fsconv.Message) string {
+	lines := []string{forwardDivider, "From: " + original.From}
+
+	if !original.Date.IsZero() {
+		lines = append(lines, "Date: "+original.Date.Format(time.RFC1123Z))
+	}
+
+	lines = append(lines, "Subject: "+original.Subject, "To: "+addresses.Join(original.To))
+
+	if len(original.Cc) > 0 {
+		lines = append(lines, "Cc: "+addresses.Join(original.Cc))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,177 @@
+package drafts
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/convert"
+	"github.com/deifyed/fsmail/pkg/fsconv"
+	"github.com/sebdah/goldie/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReply(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withOriginal fsconv.Message
+		withFrom     string
+		withAll      bool
+	}{
+		{
+			name: "Should reply to the sender",
+			withOriginal: fsconv.Message{
+				From:      "Jane Doe <jane@example.com>",
+				To:        []string{"Me <me@example.com>", "john@example.com"},
+				Subject:   "Lunch?",
+				Date:      time.Date(2022, time.October, 3, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+				MessageID: "first@example.com",
+				Body:      strings.NewReader("Pizza or sushi?\n\nJane"),
+			},
+			withFrom: "Me <me@example.com>",
+		},
+		{
+			name: "Should reply to everyone except myself",
+			withOriginal: fsconv.Message{
+				From:       "jane@example.com",
+				To:         []string{"ME@example.com", "john@example.com"},
+				Cc:         []string{"Jane Doe <jane@example.com>", "jim@example.com"},
+				Subject:    "Re: RE: Lunch?",
+				MessageID:  "second@example.com",
+				References: []string{"first@example.com"},
+				Body:       strings.NewReader("Sushi it is"),
+			},
+			withFrom: "me@example.com",
+			withAll:  true,
+		},
+		{
+			name: "Should reply to the Reply-To addresses",
+			withOriginal: fsconv.Message{
+				From:    "noreply@example.com",
+				ReplyTo: []string{"Support <support@example.com>"},
+				To:      []string{"me@example.com"},
+				Subject: "Your ticket",
+				Body:    strings.NewReader("How did we do?"),
+			},
+			withFrom: "me@example.com",
+		},
+		{
+			name: "Should reply to the original recipients of my own message",
+			withOriginal: fsconv.Message{
+				From:    "me@example.com",
+				To:      []string{"jane@example.com"},
+				Subject: "Lunch?",
+				Body:    strings.NewReader("Pizza or sushi?"),
+			},
+			withFrom: "me@example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			draft, err := Reply(tc.withOriginal, tc.withFrom, tc.withAll)
+			assert.NoError(t, err)
+
+			raw, err := io.ReadAll(convert.ToReader(draft))
+			assert.NoError(t, err)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), raw)
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	original := fsconv.Message{
+		From:      "accounting@example.com",
+		To:        []string{"me@example.com"},
+		Subject:   "Fw: Invoice",
+		MessageID: "first@example.com",
+		Body:      strings.NewReader("See attached"),
+		Attachments: []fsconv.Attachment{
+			{Filename: "invoice.pdf", Content: []byte("mock invoice")},
+		},
+	}
+
+	draft, err := Forward(original, "me@example.com")
+	assert.NoError(t, err)
+
+	filename, err := Write(fs, "/outbox", draft, original.Attachments)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Fwd_-Invoice", filename)
+
+	content, err := fs.ReadFile("/outbox/Fwd_-Invoice.attachments/invoice.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock invoice", string(content))
+
+	raw, err := fs.ReadFile("/outbox/" + filename)
+	assert.NoError(t, err)
+
+	message, err := convert.ToMessage(strings.NewReader(string(raw)))
+	assert.NoError(t, err)
+	assert.True(t, message.Draft)
+	assert.Equal(t, []string{"Fwd_-Invoice.attachments/invoice.pdf"}, message.Attachments)
+
+	g := goldie.New(t)
+
+	g.Assert(t, t.Name(), raw)
+}
+
+func TestWithPrefix(t *testing.T) {
+	testCases := []struct {
+		name          string
+		withSubject   string
+		withPrefix    string
+		withKnown     []string
+		expectSubject string
+	}{
+		{
+			name:          "Should add a prefix",
+			withSubject:   "Hello",
+			withPrefix:    replyPrefix,
+			withKnown:     replyPrefixes,
+			expectSubject: "Re: Hello",
+		},
+		{
+			name:          "Should not duplicate prefixes",
+			withSubject:   "RE: Re:Hello",
+			withPrefix:    replyPrefix,
+			withKnown:     replyPrefixes,
+			expectSubject: "Re: Hello",
+		},
+		{
+			name:          "Should keep prefixes of another kind",
+			withSubject:   "Fwd: Hello",
+			withPrefix:    replyPrefix,
+			withKnown:     replyPrefixes,
+			expectSubject: "Re: Fwd: Hello",
+		},
+		{
+			name:          "Should handle empty subjects",
+			withSubject:   "",
+			withPrefix:    forwardPrefix,
+			withKnown:     forwardPrefixes,
+			expectSubject: "Fwd:",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectSubject, withPrefix(tc.withSubject, tc.withPrefix, tc.withKnown))
+		})
+	}
+}
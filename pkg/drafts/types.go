@@ -0,0 +1,17 @@
+package drafts
+
+const (
+	replyPrefix   = "Re:"
+	forwardPrefix = "Fwd:"
+
+	forwardDivider = "---------- Forwarded message ----------"
+
+	defaultDirectoryPermissions = 0o700
+)
+
+var (
+	// replyPrefixes contains the subject prefixes used for replies, including common translations
+	replyPrefixes = []string{"re:", "aw:", "sv:"}
+	// forwardPrefixes contains the subject prefixes used for forwards, including common translations
+	forwardPrefixes = []string{"fwd:", "fw:", "wg:", "vs:"}
+)
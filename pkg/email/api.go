@@ -1,112 +1,103 @@
 package email
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
-	"github.com/emersion/go-imap"
-	"github.com/emersion/go-imap/client"
 	"gopkg.in/gomail.v2"
 )
 
-func FetchInbox(log logger, credentials Credentials) ([]Message, error) {
-	log.Debug("Connecting to IMAP server")
+func SendMessages(log logger, credentials Credentials, messages []Message) ([]string, error) {
+	dialer, err := newDialer(credentials)
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := client.DialTLS(credentials.IMAPServerAddress, nil)
+	sender, err := dialer.Dial()
 	if err != nil {
 		return nil, fmt.Errorf("dialing: %w", err)
 	}
 
 	defer func() {
-		_ = client.Logout()
+		_ = sender.Close()
 	}()
 
-	log.Debug("Logging in")
-
-	if err = client.Login(credentials.Username, credentials.Password); err != nil {
-		return nil, fmt.Errorf("logging in: %w", err)
-	}
-
-	inbox, err := client.Select("INBOX", false)
-	if err != nil {
-		return nil, fmt.Errorf("selecting INBOX: %w", err)
-	}
-
-	if inbox.Messages == 0 {
-		return nil, nil
-	}
-
-	seqset := new(imap.SeqSet)
-	seqset.AddNum(inbox.Messages)
-
-	var section imap.BodySectionName
-	items := []imap.FetchItem{section.FetchItem()}
-
-	messages := make(chan *imap.Message, 1)
+	receipts := make([]string, 0, len(messages))
 
-	log.Debug("Initiating fetch")
+	for _, message := range messages {
+		m, rawBody, err := compose(message)
+		if err != nil {
+			return receipts, fmt.Errorf("composing message: %w", err)
+		}
 
-	go func() {
-		if err := client.Fetch(seqset, items, messages); err != nil {
-			fmt.Println("Fetch error:", err)
+		if err := gomail.Send(sender, m); err != nil {
+			return receipts, fmt.Errorf("sending message: %w", err)
 		}
-	}()
 
-	convertedMessages, err := handleMessages(section, messages)
-	if err != nil {
-		return nil, fmt.Errorf("handling messages: %w", err)
+		receipts = append(receipts, CalculateReceipt(message.From, message.To, message.Subject, rawBody))
+
+		time.Sleep(1 * time.Second)
 	}
 
-	return convertedMessages, nil
+	return receipts, nil
 }
 
-func SendMessages(log logger, credentials Credentials, messages []Message) ([]string, error) {
-	host, port, err := parseServerAddress(credentials.SMTPServerAddress)
+// Render knows how to turn a message into RFC 5322 bytes, as they would be sent
+func Render(message Message) ([]byte, error) {
+	m, _, err := compose(message)
 	if err != nil {
-		return nil, fmt.Errorf("parsing server address: %w", err)
+		return nil, fmt.Errorf("composing message: %w", err)
 	}
 
-	dialer := gomail.NewDialer(host, port, credentials.Username, credentials.Password)
+	buf := bytes.Buffer{}
 
-	sender, err := dialer.Dial()
+	_, err = m.WriteTo(&buf)
 	if err != nil {
-		return nil, fmt.Errorf("dialing: %w", err)
+		return nil, fmt.Errorf("writing message: %w", err)
 	}
 
-	receipts := make([]string, 0, len(messages))
+	return buf.Bytes(), nil
+}
 
-	for _, message := range messages {
-		m := gomail.NewMessage()
-		m.SetHeader("From", message.From)
-		m.SetHeader("To", message.To)
-		m.SetHeader("Subject", message.Subject)
+// compose builds the MIME message for an outgoing message. It returns the body as written in the message as well
+func compose(message Message) (*gomail.Message, string, error) {
+	m := gomail.NewMessage()
 
-		rawBody, err := io.ReadAll(message.Body)
-		if err != nil {
-			return nil, fmt.Errorf("reading message body: %w", err)
-		}
+	err := setAddressHeaders(m, message)
+	if err != nil {
+		return nil, "", fmt.Errorf("setting address headers: %w", err)
+	}
 
-		m.SetBody("text/html", string(rawBody))
+	m.SetHeader("Subject", message.Subject)
+	setThreadingHeaders(m, message)
+	setCustomHeaders(m, message)
 
-		if err := gomail.Send(sender, m); err != nil {
-			return nil, fmt.Errorf("sending message: %w", err)
-		}
+	rawBody, err := io.ReadAll(message.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading message body: %w", err)
+	}
 
-		receipts = append(receipts, CalculateReceipt(message.From, message.To, message.Subject, string(rawBody)))
+	err = setBody(m, message.Format, string(rawBody))
+	if err != nil {
+		return nil, "", fmt.Errorf("setting body: %w", err)
+	}
 
-		time.Sleep(1 * time.Second)
+	for _, attachment := range message.Attachments {
+		attach(m, attachment)
 	}
 
-	return receipts, nil
+	return m, string(rawBody), nil
 }
 
-func CalculateReceipt(from, to, subject, body string) string {
+func CalculateReceipt(from string, to []string, subject, body string) string {
 	hash := sha256.New()
 
 	hash.Write([]byte(from))
-	hash.Write([]byte(to))
+	hash.Write([]byte(strings.Join(to, ",")))
 	hash.Write([]byte(subject))
 	hash.Write([]byte(body))
 
@@ -0,0 +1,212 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gomail.v2"
+)
+
+func TestRender(t *testing.T) {
+	date := time.Date(2022, time.October, 3, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		withMessage Message
+	}{
+		{
+			name: "Should send Markdown as plain text with an HTML alternative",
+			withMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "Minutes",
+				Date:    date,
+				Format:  FormatMarkdown,
+				Body:    strings.NewReader("# Minutes\n\nSee *attached*"),
+			},
+		},
+		{
+			name: "Should send an empty Markdown body as empty plain text",
+			withMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "No body",
+				Date:    date,
+				Format:  FormatMarkdown,
+				Body:    strings.NewReader(""),
+			},
+		},
+		{
+			name: "Should send plain text as a single part",
+			withMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "Plain",
+				Date:    date,
+				Format:  FormatPlain,
+				Body:    strings.NewReader("# Not a heading"),
+			},
+		},
+		{
+			name: "Should send HTML as a single part",
+			withMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"you@example.com"},
+				Subject: "HTML",
+				Date:    date,
+				Format:  FormatHTML,
+				Body:    strings.NewReader("<p>Hello</p>"),
+			},
+		},
+		{
+			name: "Should nest the alternative parts of Markdown next to attachments",
+			withMessage: Message{
+				From:        "me@example.com",
+				To:          []string{"you@example.com"},
+				Subject:     "Report",
+				Date:        date,
+				Format:      FormatMarkdown,
+				Body:        strings.NewReader("See *attached*"),
+				Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("mock report")}},
+			},
+		},
+		{
+			name: "Should leave Bcc recipients out of the headers",
+			withMessage: Message{
+				From:    "Me <me@example.com>",
+				To:      []string{"Jane Doe <jane@example.com>"},
+				Cc:      []string{"john@example.com"},
+				Bcc:     []string{"boss@example.com", "Audit <audit@example.com>"},
+				Subject: "Report",
+				Date:    date,
+				Format:  FormatPlain,
+				Body:    strings.NewReader("Mock content"),
+			},
+		},
+		{
+			name: "Should attach files with their content type and filename",
+			withMessage: Message{
+				From:    "me@example.com",
+				To:      []string{"accounting@example.com"},
+				Subject: "Invoice",
+				Date:    date,
+				Format:  FormatPlain,
+				Body:    strings.NewReader("See attached"),
+				Attachments: []Attachment{
+					{Filename: "invoice.pdf", ContentType: "application/pdf", Content: []byte("mock invoice")},
+					{Filename: "receipt.png", Content: []byte("\x89PNG\r\n\x1a\nmock receipt")},
+					{Filename: "notes", Content: []byte{0x00, 0x01}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			raw, err := Render(tc.withMessage)
+			assert.NoError(t, err)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), normalize(raw))
+		})
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	_, err := Render(Message{From: "me@example.com", Format: "rtf", Body: strings.NewReader("")})
+
+	assert.ErrorIs(t, err, errUnknownFormat)
+}
+
+func TestComposeEnvelope(t *testing.T) {
+	message := Message{
+		From:    "Me <me@example.com>",
+		To:      []string{"Jane Doe <jane@example.com>"},
+		Cc:      []string{"john@example.com"},
+		Bcc:     []string{"boss@example.com", "Audit <audit@example.com>"},
+		Subject: "Report",
+		Format:  FormatPlain,
+		Body:    strings.NewReader("Mock content"),
+	}
+
+	m, _, err := compose(message)
+	assert.NoError(t, err)
+
+	var (
+		envelopeFrom string
+		envelopeTo   []string
+		transmitted  bytes.Buffer
+	)
+
+	err = gomail.Send(gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		envelopeFrom = from
+		envelopeTo = to
+
+		_, err := msg.WriteTo(&transmitted)
+
+		return err
+	}), m)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "me@example.com", envelopeFrom)
+	assert.ElementsMatch(t,
+		[]string{"jane@example.com", "john@example.com", "boss@example.com", "audit@example.com"}, envelopeTo)
+
+	assert.NotContains(t, strings.ToLower(transmitted.String()), "bcc")
+	assert.NotContains(t, transmitted.String(), "boss@example.com")
+	assert.NotContains(t, transmitted.String(), "audit@example.com")
+}
+
+var boundaryPattern = regexp.MustCompile(`boundary=([0-9a-f]+)`)
+
+// normalize replaces the random multipart boundaries and sorts the fields of every header block, as gomail writes them
+// in random order, to make rendered messages comparable
+func normalize(raw []byte) []byte {
+	result := strings.ReplaceAll(string(raw), "\r\n", "\n")
+
+	for index, match := range boundaryPattern.FindAllStringSubmatch(result, -1) {
+		result = strings.ReplaceAll(result, match[1], fmt.Sprintf("mock-boundary-%d", index+1))
+	}
+
+	lines := strings.Split(result, "\n")
+	output := make([]string, 0, len(lines))
+	inHeader := true
+
+	var fields []string
+
+	for _, line := range lines {
+		switch {
+		case inHeader && line == "":
+			sort.Strings(fields)
+
+			output = append(output, fields...)
+			output = append(output, line)
+			fields = nil
+			inHeader = false
+		case inHeader && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(fields) > 0:
+			fields[len(fields)-1] += "\n" + line
+		case inHeader:
+			fields = append(fields, line)
+		default:
+			output = append(output, line)
+
+			// Every part of a multipart message starts with a header block
+			inHeader = strings.HasPrefix(line, "--mock-boundary-") && !strings.HasSuffix(line, "--")
+		}
+	}
+
+	return []byte(strings.Join(append(output, fields...), "\n"))
+}
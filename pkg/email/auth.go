@@ -0,0 +1,102 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+
+	"github.com/emersion/go-sasl"
+)
+
+// SASL mechanisms authenticating with an OAuth 2.0 access token
+const (
+	MechanismXOAUTH2     = "XOAUTH2"
+	MechanismOAUTHBEARER = "OAUTHBEARER"
+)
+
+var (
+	errUnknownMechanism = errors.New("unknown mechanism")
+	errInsecureAuth     = errors.New("refusing to send an access token over an unencrypted connection")
+)
+
+// usesToken knows if the credentials authenticate with an access token instead of a password
+func (c Credentials) usesToken() bool {
+	return c.Token != nil
+}
+
+// saslClient creates a SASL client authenticating with a fresh access token
+func saslClient(credentials Credentials, serverAddress string) (sasl.Client, error) {
+	token, err := credentials.Token()
+	if err != nil {
+		return nil, fmt.Errorf("acquiring access token: %w", err)
+	}
+
+	switch credentials.Mechanism {
+	case MechanismXOAUTH2, "":
+		return &xoauth2Client{username: credentials.Username, token: token}, nil
+	case MechanismOAUTHBEARER:
+		host, port, err := parseServerAddress(serverAddress)
+		if err != nil {
+			return nil, fmt.Errorf("parsing server address: %w", err)
+		}
+
+		return sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
+			Username: credentials.Username,
+			Token:    token,
+			Host:     host,
+			Port:     port,
+		}), nil
+	default:
+		return nil, fmt.Errorf("%w %q, expected %s or %s",
+			errUnknownMechanism, credentials.Mechanism, MechanismXOAUTH2, MechanismOAUTHBEARER)
+	}
+}
+
+// xoauth2Client implements the XOAUTH2 mechanism, as used by Google and Microsoft
+type xoauth2Client struct {
+	username string
+	token    string
+}
+
+func (c *xoauth2Client) Start() (string, []byte, error) {
+	return MechanismXOAUTH2, []byte("user=" + c.username + "\x01auth=Bearer " + c.token + "\x01\x01"), nil
+}
+
+// Next answers the error the server sends as a challenge with an empty response, after which the server fails the
+// authentication
+func (c *xoauth2Client) Next([]byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// smtpAuth adapts a SASL client to the authentication of net/smtp, which gomail uses
+type smtpAuth struct {
+	client sasl.Client
+}
+
+func (a smtpAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, only allow unencrypted connections to the local machine
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errInsecureAuth
+	}
+
+	return a.client.Start()
+}
+
+func (a smtpAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	return a.client.Next(fromServer)
+}
+
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,5 @@
+package email
+
+import "errors"
+
+var errUnknownFormat = errors.New("unknown format")
@@ -0,0 +1,327 @@
+package email
+
+import (
+	"fmt"
+
+	"github.com/deifyed/fsmail/pkg/messageid"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+)
+
+// DialIMAP knows how to open an authenticated connection to the IMAP server
+func DialIMAP(log logger, credentials Credentials) (*IMAPClient, error) {
+	log.Debug("Connecting to IMAP server")
+
+	c, err := dialIMAP(credentials.IMAPServerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("dialing: %w", err)
+	}
+
+	log.Debug("Logging in")
+
+	if err = login(c, credentials); err != nil {
+		_ = c.Logout()
+
+		return nil, fmt.Errorf("logging in: %w", err)
+	}
+
+	return &IMAPClient{log: log, client: c}, nil
+}
+
+func login(c *client.Client, credentials Credentials) error {
+	if !credentials.usesToken() {
+		return c.Login(credentials.Username, credentials.Password)
+	}
+
+	auth, err := saslClient(credentials, credentials.IMAPServerAddress)
+	if err != nil {
+		return err
+	}
+
+	return c.Authenticate(auth)
+}
+
+// Close knows how to log out and close the connection
+func (c *IMAPClient) Close() error {
+	return c.client.Logout()
+}
+
+// ListMailboxes knows how to list every selectable mailbox on the server
+func (c *IMAPClient) ListMailboxes() ([]Mailbox, error) {
+	infos := make(chan *imap.MailboxInfo, fetchBufferSize)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.client.List("", "*", infos)
+	}()
+
+	mailboxes := make([]Mailbox, 0)
+
+	for info := range infos {
+		if hasAttribute(info.Attributes, imap.NoSelectAttr) {
+			continue
+		}
+
+		mailboxes = append(mailboxes, Mailbox{Name: info.Name, Delimiter: info.Delimiter})
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	return mailboxes, nil
+}
+
+// Select knows how to select a mailbox for the following operations. It returns the UIDVALIDITY of the mailbox
+func (c *IMAPClient) Select(name string) (uint32, error) {
+	status, err := c.client.Select(name, false)
+	if err != nil {
+		return 0, fmt.Errorf("selecting %s: %w", name, err)
+	}
+
+	return status.UidValidity, nil
+}
+
+// FetchNew knows how to download the messages in the selected mailbox with a UID higher than lastUID
+func (c *IMAPClient) FetchNew(lastUID uint32) ([]Message, error) {
+	if c.client.Mailbox().Messages == 0 {
+		return nil, nil
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddRange(lastUID+1, 0)
+
+	var section imap.BodySectionName
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchFlags, section.FetchItem()}
+
+	messages := make(chan *imap.Message, fetchBufferSize)
+	done := make(chan error, 1)
+
+	c.log.Debugf("Initiating fetch of UIDs %s", seqset)
+
+	go func() {
+		done <- c.client.UidFetch(seqset, items, messages)
+	}()
+
+	convertedMessages := handleMessages(c.log, section, lastUID, messages)
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("fetching: %w", err)
+	}
+
+	return convertedMessages, nil
+}
+
+// FetchFlags knows how to retrieve the flags of every message in the selected mailbox, indexed by UID
+func (c *IMAPClient) FetchFlags() (map[uint32][]string, error) {
+	result := make(map[uint32][]string)
+
+	if c.client.Mailbox().Messages == 0 {
+		return result, nil
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddRange(1, 0)
+
+	messages := make(chan *imap.Message, fetchBufferSize)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.client.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchFlags}, messages)
+	}()
+
+	for msg := range messages {
+		result[msg.Uid] = msg.Flags
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("fetching: %w", err)
+	}
+
+	return result, nil
+}
+
+// SearchMessageID knows how to find the UIDs of the messages in the selected mailbox with the Message-ID messageID
+func (c *IMAPClient) SearchMessageID(messageID string) ([]uint32, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Set("Message-Id", messageid.Format(messageID))
+
+	uids, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("searching: %w", err)
+	}
+
+	return uids, nil
+}
+
+// StoreFlags knows how to add and remove flags on a message in the selected mailbox
+func (c *IMAPClient) StoreFlags(uid uint32, added []string, removed []string) error {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+
+	if len(added) > 0 {
+		err := c.client.UidStore(seqset, imap.FormatFlagsOp(imap.AddFlags, true), toInterfaces(added), nil)
+		if err != nil {
+			return fmt.Errorf("adding flags: %w", err)
+		}
+	}
+
+	if len(removed) > 0 {
+		err := c.client.UidStore(seqset, imap.FormatFlagsOp(imap.RemoveFlags, true), toInterfaces(removed), nil)
+		if err != nil {
+			return fmt.Errorf("removing flags: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func toInterfaces(items []string) []interface{} {
+	result := make([]interface{}, len(items))
+
+	for index, item := range items {
+		result[index] = item
+	}
+
+	return result
+}
+
+func hasAttribute(attributes []string, attribute string) bool {
+	for _, item := range attributes {
+		if item == attribute {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WatchMailbox knows how to keep an IDLE connection open on a mailbox. A signal is sent on notify every time the
+// server announces a change in the number of messages. It blocks until stop is closed or the connection fails
+func WatchMailbox(log logger, credentials Credentials, name string, notify chan<- struct{}, stop <-chan struct{}) error {
+	c, err := DialIMAP(log, credentials)
+	if err != nil {
+		return fmt.Errorf("connecting: %w", err)
+	}
+
+	defer func() {
+		_ = c.Close()
+	}()
+
+	updates := make(chan client.Update, fetchBufferSize)
+	c.client.Updates = updates
+
+	_, err = c.client.Select(name, true)
+	if err != nil {
+		return fmt.Errorf("selecting %s: %w", name, err)
+	}
+
+	done := make(chan error, 1)
+
+	log.Debugf("Idling on %s", name)
+
+	go func() {
+		done <- c.client.Idle(stop, nil)
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			if _, ok := update.(*client.MailboxUpdate); !ok {
+				continue
+			}
+
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("idling: %w", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// Delete knows how to mark messages in the selected mailbox as deleted and expunge them. Only the given messages are
+// expunged when the server supports UIDPLUS. Otherwise, expunging removes every message marked as deleted in the
+// mailbox, including messages other clients marked, so it is only done when expungeAll is set. The messages are left
+// marked as deleted when it is not
+func (c *IMAPClient) Delete(uids []uint32, expungeAll bool) error {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	err := c.client.UidStore(seqset, imap.FormatFlagsOp(imap.AddFlags, true), []interface{}{imap.DeletedFlag}, nil)
+	if err != nil {
+		return fmt.Errorf("marking as deleted: %w", err)
+	}
+
+	supportsUIDPlus, err := c.client.Support(capabilityUIDPlus)
+	if err != nil {
+		return fmt.Errorf("checking capabilities: %w", err)
+	}
+
+	switch {
+	case supportsUIDPlus:
+		err = c.uidExpunge(seqset)
+	case expungeAll:
+		err = c.client.Expunge(nil)
+	default:
+		c.log.Warn(fmt.Sprintf("The server can not expunge single messages, leaving %d messages marked as deleted",
+			len(uids)))
+	}
+
+	if err != nil {
+		return fmt.Errorf("expunging: %w", err)
+	}
+
+	return nil
+}
+
+// uidExpunge knows how to expunge the messages in seqset, and no others, with UID EXPUNGE as defined by UIDPLUS
+func (c *IMAPClient) uidExpunge(seqset *imap.SeqSet) error {
+	cmd := &commands.Uid{Cmd: &imap.Command{Name: "EXPUNGE", Arguments: []interface{}{seqset}}}
+
+	status, err := c.client.Execute(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	return status.Err()
+}
+
+// Move knows how to move messages in the selected mailbox to another mailbox. Servers without support for MOVE get
+// a COPY followed by a deletion of the originals, expunged as described by Delete
+func (c *IMAPClient) Move(uids []uint32, destination string, expungeAll bool) error {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	supportsMove, err := c.client.Support(capabilityMove)
+	if err != nil {
+		return fmt.Errorf("checking capabilities: %w", err)
+	}
+
+	if supportsMove {
+		err = c.client.UidMove(seqset, destination)
+		if err != nil {
+			return fmt.Errorf("moving to %s: %w", destination, err)
+		}
+
+		return nil
+	}
+
+	err = c.client.UidCopy(seqset, destination)
+	if err != nil {
+		return fmt.Errorf("copying to %s: %w", destination, err)
+	}
+
+	err = c.Delete(uids, expungeAll)
+	if err != nil {
+		return fmt.Errorf("deleting originals: %w", err)
+	}
+
+	return nil
+}
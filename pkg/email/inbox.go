@@ -1,80 +1,231 @@
 package email
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"mime"
+	netmail "net/mail"
 	"strings"
 
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/markdown"
+	"github.com/deifyed/fsmail/pkg/messageid"
 	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/charset"
 	"github.com/emersion/go-message/mail"
 )
 
-func handleMessages(section imap.BodySectionName, messages chan *imap.Message) ([]Message, error) {
+// handleMessages parses the fetched messages with a UID higher than lastUID. Messages that can not be parsed are kept
+// raw, as failing would keep the rest of the mailbox from ever being synchronized
+func handleMessages(log logger, section imap.BodySectionName, lastUID uint32, messages chan *imap.Message) []Message {
 	result := make([]Message, 0)
 
-	for {
-		msg, ok := <-messages
-		if !ok {
-			break
+	for msg := range messages {
+		// A UID range of n:* always includes the newest message, even when its UID is lower than n
+		if msg.Uid <= lastUID {
+			continue
 		}
 
-		extractedMessage, err := extractMessage(&section, msg)
-		if err != nil {
-			return nil, fmt.Errorf("parsing message: %w", err)
-		}
+		extractedMessage := extractMessage(log, &section, msg)
+
+		extractedMessage.UID = msg.Uid
+		extractedMessage.Flags = msg.Flags
 
-		result = append(result, Message{
-			From:    extractedMessage.From,
-			To:      extractedMessage.To,
-			Subject: extractedMessage.Subject,
-			Body:    extractedMessage.Body,
-		})
+		result = append(result, extractedMessage)
 	}
 
-	return result, nil
+	return result
 }
 
-func extractMessage(section *imap.BodySectionName, rawMessage *imap.Message) (Message, error) {
-	resultMessage := Message{}
-
+func extractMessage(log logger, section *imap.BodySectionName, rawMessage *imap.Message) Message {
 	r := rawMessage.GetBody(section)
 	if r == nil {
-		fmt.Println("Server didn't returned message body")
-		resultMessage.Body = strings.NewReader("<!-- no content -->")
+		log.Warn(fmt.Sprintf("The server returned no body for message %d", rawMessage.Uid))
+
+		return Message{Body: strings.NewReader("<!-- no content -->")}
+	}
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		log.Warn(fmt.Sprintf("Keeping message %d unparsed: buffering message: %s", rawMessage.Uid, err))
+
+		return RawMessage(raw)
 	}
 
-	mailReader, err := mail.CreateReader(r)
+	parsed, err := ParseMessage(raw)
 	if err != nil {
+		log.Warn(fmt.Sprintf("Keeping message %d unparsed: %s", rawMessage.Uid, err))
+
+		return RawMessage(raw)
+	}
+
+	return parsed
+}
+
+// ParseMessage knows how to turn a raw RFC 5322 message into a Message. The raw bytes are kept in Message.Raw
+func ParseMessage(raw []byte) (Message, error) {
+	resultMessage := Message{Raw: raw}
+
+	// Parts in unknown charsets are kept undecoded instead of failing the whole message
+	mailReader, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) {
 		return Message{}, fmt.Errorf("creating mail reader: %w", err)
 	}
 
 	header := mailReader.Header
 
-	if from, err := header.AddressList("From"); err == nil {
-		resultMessage.From = from[0].Address
-	}
-	if to, err := header.AddressList("To"); err == nil {
-		resultMessage.To = to[0].Address
+	if from, err := header.AddressList("From"); err == nil && len(from) > 0 {
+		resultMessage.From = addresses.Format(from[0].Name, from[0].Address)
 	}
+
+	resultMessage.To = extractAddressList(header, "To")
+	resultMessage.Cc = extractAddressList(header, "Cc")
+	resultMessage.Bcc = extractAddressList(header, "Bcc")
+	resultMessage.ReplyTo = extractAddressList(header, "Reply-To")
+
 	if subject, err := header.Subject(); err == nil {
 		resultMessage.Subject = subject
 	}
 
+	if date, err := header.Date(); err == nil {
+		resultMessage.Date = date
+	}
+
+	if messageID, err := header.MessageID(); err == nil {
+		resultMessage.MessageID = messageID
+	}
+
+	if inReplyTo, err := header.MsgIDList("In-Reply-To"); err == nil && len(inReplyTo) > 0 {
+		resultMessage.InReplyTo = inReplyTo[0]
+	}
+
+	if references, err := header.MsgIDList("References"); err == nil {
+		resultMessage.References = references
+	}
+
+	var plainBody, htmlBody []byte
+
 	for {
 		p, err := mailReader.NextPart()
 		if err == io.EOF {
 			break
-		} else if err != nil {
+		} else if err != nil && !message.IsUnknownCharset(err) {
 			return Message{}, fmt.Errorf("reading mail part: %w", err)
 		}
 
-		switch p.Header.(type) {
+		// Parts are drained when moving on to the next one, hence the content needs to be buffered
+		content, err := io.ReadAll(p.Body)
+		if err != nil {
+			return Message{}, fmt.Errorf("reading mail part: %w", err)
+		}
+
+		switch h := p.Header.(type) {
 		case *mail.InlineHeader:
-			resultMessage.Body = p.Body
+			contentType, params, _ := h.ContentType()
+
+			// Only the first part of each kind is kept. The body is chosen among them by selectBody. Other inline
+			// parts, such as images and text/calendar invitations, are kept as attachments
+			switch {
+			case contentType == "text/html" && htmlBody == nil:
+				htmlBody = content
+			case (contentType == "text/plain" || contentType == "") && plainBody == nil:
+				plainBody = content
+			case contentType != "text/html" && contentType != "text/plain" && contentType != "":
+				resultMessage.Attachments = append(resultMessage.Attachments, Attachment{
+					Filename:    params["name"],
+					ContentType: contentType,
+					Content:     content,
+				})
+			}
 		case *mail.AttachmentHeader:
-			fmt.Println("Attachment found")
+			filename, _ := h.Filename()
+			contentType, _, _ := h.ContentType()
+
+			resultMessage.Attachments = append(resultMessage.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				Content:     content,
+			})
 		}
 	}
 
+	resultMessage.HTML = string(htmlBody)
+
+	body, err := selectBody(plainBody, htmlBody)
+	if err != nil {
+		return Message{}, fmt.Errorf("selecting body: %w", err)
+	}
+
+	resultMessage.Body = strings.NewReader(body)
+
 	return resultMessage, nil
 }
+
+// RawMessage knows how to keep a message that ParseMessage fails on. The header fields that can be read are kept, and
+// the body is left undecoded
+func RawMessage(raw []byte) Message {
+	resultMessage := Message{Raw: raw, Body: strings.NewReader(string(raw))}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return resultMessage
+	}
+
+	decoder := mime.WordDecoder{CharsetReader: charset.Reader}
+
+	decode := func(value string) string {
+		decoded, err := decoder.DecodeHeader(value)
+		if err != nil {
+			return value
+		}
+
+		return decoded
+	}
+
+	resultMessage.From = decode(parsed.Header.Get("From"))
+	resultMessage.Subject = decode(parsed.Header.Get("Subject"))
+	resultMessage.MessageID = messageid.Parse(parsed.Header.Get("Message-Id"))
+
+	if date, err := parsed.Header.Date(); err == nil {
+		resultMessage.Date = date
+	}
+
+	body, err := io.ReadAll(parsed.Body)
+	if err == nil {
+		resultMessage.Body = strings.NewReader(string(body))
+	}
+
+	return resultMessage
+}
+
+// selectBody picks the most readable representation of a message body, converting HTML to Markdown when no plain
+// text alternative exists
+func selectBody(plainBody []byte, htmlBody []byte) (string, error) {
+	if plainBody != nil || htmlBody == nil {
+		return string(plainBody), nil
+	}
+
+	body, err := markdown.FromHTML(string(htmlBody))
+	if err != nil {
+		return "", fmt.Errorf("converting HTML to Markdown: %w", err)
+	}
+
+	return body, nil
+}
+
+func extractAddressList(header mail.Header, field string) []string {
+	list, err := header.AddressList(field)
+	if err != nil {
+		return nil
+	}
+
+	result := make([]string, len(list))
+
+	for index, item := range list {
+		result[index] = addresses.Format(item.Name, item.Address)
+	}
+
+	return result
+}
@@ -0,0 +1,176 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMessageCharsets(t *testing.T) {
+	testCases := []struct {
+		name          string
+		withRaw       string
+		expectSubject string
+		expectBody    string
+	}{
+		{
+			name: "Should decode ISO-8859-1 bodies",
+			withRaw: "From: jane@example.com\r\nSubject: =?ISO-8859-1?Q?Caf=E9?=\r\n" +
+				"Content-Type: text/plain; charset=ISO-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+				"Cr=E8me br=FBl=E9e\r\n",
+			expectSubject: "Café",
+			expectBody:    "Crème brûlée\r\n",
+		},
+		{
+			name: "Should decode Windows-1252 bodies",
+			withRaw: "From: jane@example.com\r\nSubject: Quotes\r\n" +
+				"Content-Type: text/plain; charset=windows-1252\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+				"=93Hello=94 =80\r\n",
+			expectSubject: "Quotes",
+			expectBody:    "“Hello” €\r\n",
+		},
+		{
+			name: "Should keep bodies in unknown charsets undecoded",
+			withRaw: "From: jane@example.com\r\nSubject: Unknown\r\n" +
+				"Content-Type: text/plain; charset=x-mock\r\n\r\n" +
+				"Mock content\r\n",
+			expectSubject: "Unknown",
+			expectBody:    "Mock content\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			message, err := ParseMessage([]byte(tc.withRaw))
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(message.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectSubject, message.Subject)
+			assert.Equal(t, tc.expectBody, string(body))
+		})
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	raw := []byte("From: jane@example.com\r\nSubject: =?ISO-8859-1?Q?Caf=E9?=\r\nMessage-ID: <first@example.com>\r\n" +
+		"Content-Type: multipart/mixed\r\n\r\nMock content\r\n")
+
+	message := RawMessage(raw)
+
+	body, err := io.ReadAll(message.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, raw, message.Raw)
+	assert.Equal(t, "jane@example.com", message.From)
+	assert.Equal(t, "Café", message.Subject)
+	assert.Equal(t, "first@example.com", message.MessageID)
+	assert.Equal(t, "Mock content\r\n", string(body))
+}
+
+func TestParseMessage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		withRaw string
+	}{
+		{
+			name: "Should use the plain text of a single part message",
+			withRaw: "From: Jane Doe <jane@example.com>\r\nTo: me@example.com\r\nSubject: Lunch\r\n" +
+				"Date: Mon, 03 Oct 2022 12:00:00 +0200\r\nMessage-ID: <first@example.com>\r\n" +
+				"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+				"Noon?\r\n",
+		},
+		{
+			name: "Should prefer the plain text of an alternative",
+			withRaw: "From: jane@example.com\r\nTo: me@example.com\r\nSubject: Newsletter\r\n" +
+				"Content-Type: multipart/alternative; boundary=mock\r\n\r\n" +
+				"--mock\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n<h1>News</h1>\r\n" +
+				"--mock\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nNews\r\n" +
+				"--mock--\r\n",
+		},
+		{
+			name: "Should convert HTML to Markdown without a plain text part",
+			withRaw: "From: jane@example.com\r\nTo: me@example.com\r\nSubject: Newsletter\r\n" +
+				"Content-Type: text/html; charset=UTF-8\r\n\r\n" +
+				"<h1>News</h1><ul><li>One</li><li><a href=\"https://example.com\">Two</a></li></ul>\r\n",
+		},
+		{
+			name: "Should keep inline text parts other than plain text as attachments",
+			withRaw: "From: jane@example.com\r\nTo: me@example.com\r\nSubject: Invitation\r\n" +
+				"Content-Type: multipart/mixed; boundary=mock\r\n\r\n" +
+				"--mock\r\nContent-Type: text/calendar; charset=UTF-8; method=REQUEST; name=invite.ics\r\n\r\n" +
+				"BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n" +
+				"--mock\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nJoin us\r\n" +
+				"--mock--\r\n",
+		},
+		{
+			name: "Should extract attachments",
+			withRaw: "From: jane@example.com\r\nTo: me@example.com\r\nSubject: Invoice\r\n" +
+				"Content-Type: multipart/mixed; boundary=mock\r\n\r\n" +
+				"--mock\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nSee attached\r\n" +
+				"--mock\r\nContent-Type: application/pdf\r\n" +
+				"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+				"bW9jayBpbnZvaWNl\r\n" +
+				"--mock\r\nContent-Type: text/plain; charset=UTF-8\r\n" +
+				"Content-Disposition: attachment; filename=\"=?UTF-8?Q?notes=C3=A6.txt?=\"\r\n\r\nmock notes\r\n" +
+				"--mock--\r\n",
+		},
+		{
+			name: "Should extract inline images as attachments",
+			withRaw: "From: jane@example.com\r\nTo: me@example.com\r\nSubject: Photo\r\n" +
+				"Content-Type: multipart/related; boundary=mock\r\n\r\n" +
+				"--mock\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n<p><img src=\"cid:photo\"></p>\r\n" +
+				"--mock\r\nContent-Type: image/png; name=photo.png\r\nContent-ID: <photo>\r\n" +
+				"Content-Disposition: inline\r\n\r\nmock image\r\n" +
+				"--mock--\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			message, err := ParseMessage([]byte(tc.withRaw))
+			assert.NoError(t, err)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), describe(t, message))
+		})
+	}
+}
+
+// describe writes the fields of a parsed message in a readable form
+func describe(t *testing.T, message Message) []byte {
+	t.Helper()
+
+	body, err := io.ReadAll(message.Body)
+	assert.NoError(t, err)
+
+	buf := bytes.Buffer{}
+
+	fmt.Fprintf(&buf, "From: %s\nTo: %s\nCc: %s\nSubject: %s\nDate: %s\nMessage-ID: %s\n",
+		message.From, strings.Join(message.To, ", "), strings.Join(message.Cc, ", "), message.Subject,
+		message.Date.Format(time.RFC1123Z), message.MessageID)
+
+	fmt.Fprintf(&buf, "\n[Body]\n%s\n[HTML]\n%s\n", body, message.HTML)
+
+	for _, attachment := range message.Attachments {
+		fmt.Fprintf(&buf, "[Attachment %q %s]\n%s\n", attachment.Filename, attachment.ContentType, attachment.Content)
+	}
+
+	return buf.Bytes()
+}
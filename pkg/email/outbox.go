@@ -2,19 +2,189 @@ package email
 
 import (
 	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path"
 	"strconv"
 	"strings"
+
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/markdown"
+	"github.com/deifyed/fsmail/pkg/messageid"
+	"gopkg.in/gomail.v2"
 )
 
 func parseServerAddress(serverAddress string) (string, int, error) {
-	parts := strings.Split(serverAddress, ":")
-
-	host := parts[0]
+	host, rawPort, err := net.SplitHostPort(serverAddress)
+	if err != nil || host == "" {
+		return "", 0, fmt.Errorf("%w %q, expected host:port", errInvalidAddress, serverAddress)
+	}
 
-	port, err := strconv.Atoi(parts[1])
+	port, err := strconv.Atoi(rawPort)
 	if err != nil {
 		return "", 0, fmt.Errorf("converting port from string to int: %w", err)
 	}
 
 	return host, port, nil
 }
+
+func attach(m *gomail.Message, attachment Attachment) {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = DetectContentType(attachment.Filename, attachment.Content)
+	}
+
+	formattedContentType := mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})
+	if formattedContentType == "" {
+		formattedContentType = mime.FormatMediaType("application/octet-stream", map[string]string{"name": attachment.Filename})
+	}
+
+	m.Attach(attachment.Filename,
+		gomail.SetHeader(map[string][]string{"Content-Type": {formattedContentType}}),
+		gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(attachment.Content)
+
+			return err
+		}),
+	)
+}
+
+// DetectContentType knows how to figure out the media type of a file, first based on its extension, then its content
+func DetectContentType(filename string, content []byte) string {
+	if contentType := mime.TypeByExtension(path.Ext(filename)); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			return mediaType
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	return mediaType
+}
+
+// setAddressHeaders sets the address headers of an outgoing message. Bcc recipients are part of the envelope, but
+// never written to the transmitted headers
+func setAddressHeaders(m *gomail.Message, message Message) error {
+	fields := []struct {
+		name string
+		list []string
+	}{
+		{name: "From", list: []string{message.From}},
+		{name: "To", list: message.To},
+		{name: "Cc", list: message.Cc},
+		{name: "Bcc", list: message.Bcc},
+		{name: "Reply-To", list: message.ReplyTo},
+	}
+
+	for _, field := range fields {
+		if len(field.list) == 0 {
+			continue
+		}
+
+		formatted := make([]string, len(field.list))
+
+		for index, item := range field.list {
+			name, address, err := addresses.Parse(item)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", field.name, err)
+			}
+
+			formatted[index] = m.FormatAddress(address, name)
+		}
+
+		m.SetHeader(field.name, formatted...)
+	}
+
+	return nil
+}
+
+// setThreadingHeaders sets the headers identifying an outgoing message and the conversation it belongs to. Headers the
+// message does not specify are left to gomail and the SMTP server
+func setThreadingHeaders(m *gomail.Message, message Message) {
+	if !message.Date.IsZero() {
+		m.SetDateHeader("Date", message.Date)
+	}
+
+	if message.MessageID != "" {
+		m.SetHeader("Message-ID", messageid.Format(message.MessageID))
+	}
+
+	if message.InReplyTo != "" {
+		m.SetHeader("In-Reply-To", messageid.Format(message.InReplyTo))
+	}
+
+	if len(message.References) > 0 {
+		m.SetHeader("References", messageid.Format(message.References...))
+	}
+}
+
+// setCustomHeaders sets the additional header fields of an outgoing message. Header values can not span several
+// lines, so folded values are unfolded first
+func setCustomHeaders(m *gomail.Message, message Message) {
+	for key, values := range message.Headers {
+		unfolded := make([]string, len(values))
+
+		for index, value := range values {
+			unfolded[index] = strings.Join(strings.Fields(value), " ")
+		}
+
+		m.SetHeader(key, unfolded...)
+	}
+}
+
+// setBody sets the body of an outgoing message. Markdown bodies are sent as multipart/alternative, with the source as
+// the plain text part and a rendered HTML part. Empty Markdown bodies are sent as plain text
+func setBody(m *gomail.Message, format string, body string) error {
+	switch format {
+	case FormatPlain:
+		m.SetBody("text/plain", body)
+	case FormatHTML:
+		m.SetBody("text/html", body)
+	case FormatMarkdown:
+		// An HTML rendering of nothing is an empty part, which some clients show instead of the plain text one
+		if strings.TrimSpace(body) == "" {
+			m.SetBody("text/plain", body)
+
+			return nil
+		}
+
+		rendered, err := markdown.ToHTML(body)
+		if err != nil {
+			return fmt.Errorf("rendering markdown: %w", err)
+		}
+
+		m.SetBody("text/plain", body)
+		m.AddAlternative("text/html", rendered)
+	default:
+		return fmt.Errorf("format %q: %w", format, errUnknownFormat)
+	}
+
+	return nil
+}
+
+// newDialer prepares a connection to the SMTP server, authenticating with an access token when the credentials have one
+func newDialer(credentials Credentials) (*gomail.Dialer, error) {
+	host, port, err := parseServerAddress(credentials.SMTPServerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parsing server address: %w", err)
+	}
+
+	dialer := gomail.NewDialer(host, port, credentials.Username, credentials.Password)
+
+	if credentials.usesToken() {
+		auth, err := saslClient(credentials, credentials.SMTPServerAddress)
+		if err != nil {
+			return nil, fmt.Errorf("preparing authentication: %w", err)
+		}
+
+		dialer.Auth = smtpAuth{client: auth}
+	}
+
+	return dialer, nil
+}
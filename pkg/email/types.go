@@ -1,22 +1,90 @@
 package email
 
-import "io"
+import (
+	"io"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
 
 type Credentials struct {
 	IMAPServerAddress string
 	SMTPServerAddress string
 	Username          string
 	Password          string
+	// Token provides an OAuth 2.0 access token. When set, it is used to authenticate instead of Password
+	Token func() (string, error)
+	// Mechanism is the SASL mechanism authenticating with Token. One of MechanismXOAUTH2 or MechanismOAUTHBEARER
+	Mechanism string
 }
 
 type Message struct {
+	UID     uint32
 	From    string
-	To      string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo []string
 	Subject string
-	Body    io.Reader
+	Date    time.Time
+	// MessageID, InReplyTo and References contain message IDs without angle brackets
+	MessageID  string
+	InReplyTo  string
+	References []string
+	// Format describes how the body of an outgoing message is written. One of FormatMarkdown, FormatPlain or FormatHTML
+	Format string
+	Body   io.Reader
+	// HTML contains the original HTML part of a received message. Empty when the message has no HTML part
+	HTML string
+	// Raw contains a received message exactly as the server returned it
+	Raw []byte
+	// Flags contains the IMAP flags of the message
+	Flags []string
+	// Headers contains additional header fields of an outgoing message, mapped to their values
+	Headers     map[string][]string
+	Attachments []Attachment
+}
+
+// Attachment describes a file attached to a message
+type Attachment struct {
+	// Filename is the name suggested by the sender. Might be empty or unsafe to use as a path
+	Filename    string
+	ContentType string
+	Content     []byte
 }
 
 type logger interface {
 	Debug(...interface{})
 	Debugf(string, ...interface{})
+	Warn(...interface{})
+}
+
+// IMAPClient exposes functions for dealing with an authenticated IMAP connection
+type IMAPClient struct {
+	log    logger
+	client *client.Client
 }
+
+// Mailbox describes a selectable mailbox on the IMAP server
+type Mailbox struct {
+	Name string
+	// Delimiter separates the levels of the mailbox hierarchy. Empty when the server has a flat hierarchy
+	Delimiter string
+}
+
+const fetchBufferSize = 10
+
+// Capabilities of IMAP extensions that are used when the server supports them
+const (
+	capabilityMove    = "MOVE"
+	capabilityUIDPlus = "UIDPLUS"
+)
+
+const (
+	// FormatMarkdown sends the body as plain text together with an HTML rendering of it
+	FormatMarkdown = "markdown"
+	// FormatPlain sends the body as plain text
+	FormatPlain = "plain"
+	// FormatHTML sends the body as HTML
+	FormatHTML = "html"
+)
@@ -0,0 +1,135 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"syscall"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// dialTimeout limits how long connecting to a server may take
+const dialTimeout = 10 * time.Second
+
+var (
+	errInvalidAddress = errors.New("invalid server address")
+	errUnknownHost    = errors.New("unknown host")
+	errRefused        = errors.New("connection refused")
+	errTimeout        = errors.New("connection timed out")
+	errTLS            = errors.New("TLS failed")
+	errAuthentication = errors.New("authentication failed")
+)
+
+// VerifyIMAP knows how to check that the credentials can log in to the IMAP server
+func VerifyIMAP(credentials Credentials) error {
+	c, err := dialIMAP(credentials.IMAPServerAddress)
+	if err != nil {
+		return explainDialError(err, credentials.IMAPServerAddress, "IMAP over TLS usually uses port 993")
+	}
+
+	defer func() {
+		_ = c.Logout()
+	}()
+
+	err = login(c, credentials)
+	if err != nil {
+		return explainAuthError(err, credentials)
+	}
+
+	return nil
+}
+
+// VerifySMTP knows how to check that the credentials can log in to the SMTP server
+func VerifySMTP(credentials Credentials) error {
+	dialer, err := newDialer(credentials)
+	if err != nil {
+		return err
+	}
+
+	sender, err := dialer.Dial()
+	if err != nil {
+		var protocolErr *textproto.Error
+
+		switch {
+		case errors.As(err, &protocolErr) && isAuthenticationCode(protocolErr.Code):
+			return explainAuthError(err, credentials)
+		case errors.Is(err, errInsecureAuth), err.Error() == "unencrypted connection":
+			return fmt.Errorf("%w: %s does not offer STARTTLS, use the port for SMTP over TLS, usually 465 (%s)",
+				errTLS, credentials.SMTPServerAddress, err)
+		default:
+			return explainDialError(err, credentials.SMTPServerAddress,
+				"SMTP uses TLS on port 465, and STARTTLS on port 587")
+		}
+	}
+
+	return sender.Close()
+}
+
+// dialIMAP opens a TLS connection to the IMAP server, giving up after dialTimeout
+func dialIMAP(serverAddress string) (*client.Client, error) {
+	_, _, err := parseServerAddress(serverAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.DialWithDialerTLS(&net.Dialer{Timeout: dialTimeout}, serverAddress, nil)
+}
+
+// explainDialError turns a failure to connect into an error describing what to check. portHint describes the ports
+// commonly used by the protocol
+func explainDialError(err error, serverAddress string, portHint string) error {
+	var (
+		dnsErr         *net.DNSError
+		recordErr      tls.RecordHeaderError
+		authorityErr   x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certificateErr x509.CertificateInvalidError
+		netErr         net.Error
+	)
+
+	switch {
+	case errors.Is(err, errInvalidAddress):
+		return err
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("%w: %s could not be found, check the spelling of the server address (%s)",
+			errUnknownHost, dnsErr.Name, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: nothing answers on %s, check the port. %s", errRefused, serverAddress, portHint)
+	case errors.As(err, &recordErr):
+		return fmt.Errorf("%w: %s does not speak TLS, check the port. %s", errTLS, serverAddress, portHint)
+	case errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certificateErr):
+		return fmt.Errorf("%w: the certificate of %s can not be trusted, check the server address (%s)",
+			errTLS, serverAddress, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %s did not answer within %s, check the address and port, and that no firewall blocks it",
+			errTimeout, serverAddress, dialTimeout)
+	default:
+		return fmt.Errorf("connecting to %s: %w", serverAddress, err)
+	}
+}
+
+// explainAuthError turns a rejected login into an error describing what to check
+func explainAuthError(err error, credentials Credentials) error {
+	if credentials.usesToken() {
+		return fmt.Errorf("%w: the server rejected the access token for %s, check the OAuth 2.0 scopes and mechanism (%s)",
+			errAuthentication, credentials.Username, err)
+	}
+
+	return fmt.Errorf("%w: the server rejected %s, check the username and password. Some providers require an app "+
+		"password (%s)", errAuthentication, credentials.Username, err)
+}
+
+// isAuthenticationCode knows if an SMTP reply code means the credentials were rejected
+func isAuthenticationCode(code int) bool {
+	switch code {
+	case 454, 534, 535:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	// A server that greets without TLS, like IMAP on port 143
+	plaintext, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = plaintext.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := plaintext.Accept()
+			if err != nil {
+				return
+			}
+
+			_, _ = conn.Write([]byte("* OK IMAP4rev1 ready\r\n"))
+			_ = conn.Close()
+		}
+	}()
+
+	// A port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	closedAddress := closed.Addr().String()
+	_ = closed.Close()
+
+	testCases := []struct {
+		name        string
+		withAddress string
+		expectErr   error
+	}{
+		{
+			name:        "Should reject addresses without a port",
+			withAddress: "imap.example.com",
+			expectErr:   errInvalidAddress,
+		},
+		{
+			name:        "Should reject empty addresses",
+			withAddress: "",
+			expectErr:   errInvalidAddress,
+		},
+		{
+			name:        "Should explain ports nothing listens on",
+			withAddress: closedAddress,
+			expectErr:   errRefused,
+		},
+		{
+			name:        "Should explain servers not speaking TLS",
+			withAddress: plaintext.Addr().String(),
+			expectErr:   errTLS,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := VerifyIMAP(Credentials{IMAPServerAddress: tc.withAddress, Username: "jane", Password: "secret"})
+
+			assert.ErrorIs(t, err, tc.expectErr)
+		})
+	}
+}
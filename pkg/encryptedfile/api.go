@@ -0,0 +1,239 @@
+package encryptedfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// DefaultPath returns the path of the credentials file in the user's configuration directory, e.g.
+// ~/.config/fsmail/credentials.age
+func DefaultPath() (string, error) {
+	configDirectory, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("acquiring config directory: %w", err)
+	}
+
+	return filepath.Join(configDirectory, configDirectoryName, DefaultFilename), nil
+}
+
+// ReadPassphrase knows how to acquire the passphrase of the credentials file. It is taken from the FSMAIL_PASSPHRASE
+// environment variable, and prompted for when missing and running in a terminal
+func ReadPassphrase() (string, error) {
+	passphrase, ok := os.LookupEnv(PassphraseVariable)
+	if ok {
+		return passphrase, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("set %s: %w", PassphraseVariable, errMissingPassphrase)
+	}
+
+	fmt.Fprint(os.Stderr, "Credentials file passphrase: ")
+
+	rawPassphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+
+	return string(rawPassphrase), nil
+}
+
+// Put knows how to store secrets in the encrypted file, creating the file when missing
+func (c Client) Put(name string, secrets map[string]string) error {
+	passphrase, err := c.passphrase()
+	if err != nil {
+		return err
+	}
+
+	current, err := c.read(passphrase)
+	if err != nil {
+		return err
+	}
+
+	current[c.Prefix+prefixSeparator+name] = secrets
+
+	return c.write(passphrase, current)
+}
+
+// Get knows how to retrieve secrets from the encrypted file
+func (c Client) Get(name string, key string) (string, error) {
+	passphrase, err := c.passphrase()
+	if err != nil {
+		return "", err
+	}
+
+	current, err := c.read(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	secrets, ok := current[c.Prefix+prefixSeparator+name]
+	if !ok {
+		return "", fmt.Errorf("secret %s in %s: %w", name, c.Path, errNotFound)
+	}
+
+	return secrets[key], nil
+}
+
+// Delete knows how to remove secrets from the encrypted file
+func (c Client) Delete(name string) error {
+	passphrase, err := c.passphrase()
+	if err != nil {
+		return err
+	}
+
+	current, err := c.read(passphrase)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := current[c.Prefix+prefixSeparator+name]; !ok {
+		return fmt.Errorf("secret %s in %s: %w", name, c.Path, errNotFound)
+	}
+
+	delete(current, c.Prefix+prefixSeparator+name)
+
+	return c.write(passphrase, current)
+}
+
+func (c Client) passphrase() (string, error) {
+	passphrase, err := c.Passphrase()
+	if err != nil {
+		return "", fmt.Errorf("acquiring passphrase: %w", err)
+	}
+
+	if passphrase == "" {
+		return "", errMissingPassphrase
+	}
+
+	return passphrase, nil
+}
+
+// read decrypts the file. A missing file has no secrets. The decrypted content is kept for the rest of the process, so
+// the slow key derivation only happens once per file and passphrase
+func (c Client) read(passphrase string) (content, error) {
+	key := cacheKey{fs: c.Fs, path: c.Path}
+
+	decrypted.Lock()
+	cached, ok := decrypted.files[key]
+	decrypted.Unlock()
+
+	if ok && cached.passphrase == passphrase {
+		return cached.secrets.clone(), nil
+	}
+
+	encrypted, err := c.Fs.ReadFile(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return content{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.Path, err)
+	}
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("preparing identity: %w", err)
+	}
+
+	plaintext, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s, is the passphrase right?: %w", c.Path, err)
+	}
+
+	result := content{}
+
+	err = json.NewDecoder(plaintext).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling: %w", err)
+	}
+
+	c.remember(passphrase, result)
+
+	return result, nil
+}
+
+// remember keeps the content of the file for later reads with the same passphrase
+func (c Client) remember(passphrase string, secrets content) {
+	decrypted.Lock()
+	defer decrypted.Unlock()
+
+	decrypted.files[cacheKey{fs: c.Fs, path: c.Path}] = cachedContent{passphrase: passphrase, secrets: secrets.clone()}
+}
+
+// clone copies the content, so changes to the copy leave the cached content alone. Secrets are replaced as a whole,
+// hence sharing them is safe
+func (c content) clone() content {
+	result := make(content, len(c))
+
+	for name, secrets := range c {
+		result[name] = secrets
+	}
+
+	return result
+}
+
+// write encrypts the secrets into a temporary file that replaces the file, so a failure never leaves a broken file
+func (c Client) write(passphrase string, secrets content) error {
+	payload, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("marshalling: %w", err)
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return fmt.Errorf("preparing recipient: %w", err)
+	}
+
+	if c.workFactor != 0 {
+		recipient.SetWorkFactor(c.workFactor)
+	}
+
+	encrypted := bytes.Buffer{}
+
+	writer, err := age.Encrypt(&encrypted, recipient)
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	_, err = io.Copy(writer, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	err = c.Fs.MkdirAll(path.Dir(c.Path), defaultDirectoryPermissions)
+	if err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	temporaryPath := c.Path + ".tmp"
+
+	err = c.Fs.WriteFile(temporaryPath, encrypted.Bytes(), defaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", temporaryPath, err)
+	}
+
+	err = c.Fs.Rename(temporaryPath, c.Path)
+	if err != nil {
+		return fmt.Errorf("replacing %s: %w", c.Path, err)
+	}
+
+	c.remember(passphrase, secrets)
+
+	return nil
+}
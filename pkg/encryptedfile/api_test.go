@@ -0,0 +1,92 @@
+package encryptedfile
+
+import (
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// testWorkFactor keeps key derivation fast in tests
+const testWorkFactor = 10
+
+func newTestClient(fs *afero.Afero, prefix string, passphrase string) Client {
+	return Client{
+		Fs:         fs,
+		Path:       "/config/fsmail/credentials.age",
+		Prefix:     prefix,
+		Passphrase: func() (string, error) { return passphrase, nil },
+		workFactor: testWorkFactor,
+	}
+}
+
+func TestPutGet(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	personal := newTestClient(fs, "fssmtp", "correct horse")
+	work := newTestClient(fs, "fsmail-work", "correct horse")
+
+	err := personal.Put(credentials.CredentialsSecretName, map[string]string{credentials.PasswordKey: "personal-secret"})
+	assert.NoError(t, err)
+
+	err = work.Put(credentials.CredentialsSecretName, map[string]string{credentials.PasswordKey: "work-secret"})
+	assert.NoError(t, err)
+
+	password, err := personal.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "personal-secret", password)
+
+	password, err = work.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "work-secret", password)
+
+	encrypted, err := fs.ReadFile("/config/fsmail/credentials.age")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "secret")
+
+	info, err := fs.Stat("/config/fsmail/credentials.age")
+	assert.NoError(t, err)
+	assert.Equal(t, "-rw-------", info.Mode().Perm().String())
+
+	_, err = newTestClient(fs, "fssmtp", "wrong").Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.ErrorContains(t, err, "is the passphrase right?")
+
+	err = work.Delete(credentials.CredentialsSecretName)
+	assert.NoError(t, err)
+
+	_, err = work.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, err, credentials.ErrNotFound)
+
+	password, err = personal.Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "personal-secret", password)
+}
+
+func TestMissingPassphrase(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	_, err := newTestClient(fs, "fssmtp", "").Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.ErrorIs(t, err, errMissingPassphrase)
+}
+
+func TestReadOnce(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	client := newTestClient(fs, "fssmtp", "correct horse")
+
+	err := client.Put(credentials.CredentialsSecretName, map[string]string{credentials.PasswordKey: "secret"})
+	assert.NoError(t, err)
+
+	// The file is only decrypted once, so later reads are served by the content kept in memory
+	err = fs.WriteFile("/config/fsmail/credentials.age", []byte("not encrypted"), 0o600)
+	assert.NoError(t, err)
+
+	password, err := newTestClient(fs, "fssmtp", "correct horse").Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	_, err = newTestClient(fs, "fssmtp", "wrong").Get(credentials.CredentialsSecretName, credentials.PasswordKey)
+	assert.Error(t, err)
+}
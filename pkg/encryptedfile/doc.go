@@ -0,0 +1,2 @@
+// Package encryptedfile exposes credentials kept in a file encrypted with a passphrase using age
+package encryptedfile
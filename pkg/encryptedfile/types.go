@@ -0,0 +1,62 @@
+package encryptedfile
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/spf13/afero"
+)
+
+// Client exposes the secrets kept in an encrypted file. The file can hold the secrets of several accounts, separated
+// by Prefix
+type Client struct {
+	Fs     *afero.Afero
+	Path   string
+	Prefix string
+	// Passphrase provides the passphrase the file is encrypted with
+	Passphrase func() (string, error)
+
+	// workFactor defines the cost of deriving the key from the passphrase, as the base 2 logarithm of the scrypt work
+	// factor. Zero uses the default of age
+	workFactor int
+}
+
+// content maps the names of secrets, prefixed by the account, to the secrets
+type content map[string]map[string]string
+
+// decrypted holds the content of the files decrypted by this process, as deriving the key from the passphrase is slow
+// on purpose
+var decrypted = struct {
+	sync.Mutex
+	files map[cacheKey]cachedContent
+}{files: make(map[cacheKey]cachedContent)}
+
+type cacheKey struct {
+	fs   *afero.Afero
+	path string
+}
+
+// cachedContent is only valid for the passphrase the file was decrypted with, so a wrong passphrase still fails
+type cachedContent struct {
+	passphrase string
+	secrets    content
+}
+
+const (
+	// DefaultFilename names the credentials file in the fsmail configuration directory
+	DefaultFilename = "credentials.age"
+	// PassphraseVariable names the environment variable holding the passphrase
+	PassphraseVariable = "FSMAIL_PASSPHRASE"
+
+	configDirectoryName = "fsmail"
+	prefixSeparator     = "/"
+
+	defaultFilePermissions      = 0o600
+	defaultDirectoryPermissions = 0o700
+)
+
+var (
+	errNotFound          = credentials.ErrNotFound
+	errMissingPassphrase = errors.New("missing passphrase")
+)
@@ -0,0 +1,69 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+)
+
+// Prefix knows how to derive the prefix of the variables of an account, e.g. FSMAIL_WORK for the account work
+func Prefix(accountName string) string {
+	if accountName == "" {
+		return DefaultPrefix
+	}
+
+	return DefaultPrefix + "_" + variableName(accountName)
+}
+
+// Put fails, as the variables are set by whoever runs fsmail
+func (c Client) Put(string, map[string]string) error {
+	return fmt.Errorf("set %s and %s instead: %w",
+		c.Variable(credentials.UsernameKey), c.Variable(credentials.PasswordKey), credentials.ErrReadOnly)
+}
+
+// Delete fails, as the variables are set by whoever runs fsmail
+func (c Client) Delete(string) error {
+	return fmt.Errorf("unset %s and %s instead: %w",
+		c.Variable(credentials.UsernameKey), c.Variable(credentials.PasswordKey), credentials.ErrReadOnly)
+}
+
+// Get knows how to retrieve a credential from its variable, e.g. FSMAIL_PASSWORD for the password. Server addresses
+// are configured, so they are optional
+func (c Client) Get(_ string, key string) (string, error) {
+	lookupEnv := c.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	value, ok := lookupEnv(c.Variable(key))
+	if ok {
+		return value, nil
+	}
+
+	if key == credentials.IMAPServerAddressKey || key == credentials.SMTPServerAddressKey {
+		return "", nil
+	}
+
+	return "", fmt.Errorf("%s: %w", c.Variable(key), errNotSet)
+}
+
+// Variable returns the name of the variable holding the credential identified by key
+func (c Client) Variable(key string) string {
+	return c.Prefix + "_" + variableName(key)
+}
+
+// variableName turns a name into a valid part of a variable name, e.g. smtp-server-address into SMTP_SERVER_ADDRESS
+func variableName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,87 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	variables := map[string]string{
+		"FSMAIL_USERNAME":                 "jane@example.com",
+		"FSMAIL_PASSWORD":                 "secret",
+		"FSMAIL_WORK_PASSWORD":            "work-secret",
+		"FSMAIL_WORK_SMTP_SERVER_ADDRESS": "smtp.work.com:465",
+	}
+
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := variables[name]
+
+		return value, ok
+	}
+
+	testCases := []struct {
+		name        string
+		withAccount string
+		withKey     string
+		expectValue string
+		expectErr   error
+	}{
+		{
+			name:        "Should read the variables of the unnamed account",
+			withKey:     credentials.PasswordKey,
+			expectValue: "secret",
+		},
+		{
+			name:        "Should read the variables of named accounts",
+			withAccount: "work",
+			withKey:     credentials.PasswordKey,
+			expectValue: "work-secret",
+		},
+		{
+			name:        "Should read server addresses",
+			withAccount: "work",
+			withKey:     credentials.SMTPServerAddressKey,
+			expectValue: "smtp.work.com:465",
+		},
+		{
+			name:        "Should allow missing server addresses",
+			withKey:     credentials.IMAPServerAddressKey,
+			expectValue: "",
+		},
+		{
+			name:        "Should fail on missing credentials",
+			withAccount: "work",
+			withKey:     credentials.UsernameKey,
+			expectErr:   errNotSet,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := Client{Prefix: Prefix(tc.withAccount), LookupEnv: lookupEnv}
+
+			value, err := client.Get(credentials.CredentialsSecretName, tc.withKey)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+				assert.ErrorContains(t, err, "FSMAIL_WORK_USERNAME")
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectValue, value)
+		})
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	assert.Equal(t, "FSMAIL", Prefix(""))
+	assert.Equal(t, "FSMAIL_MY_WORK_2", Prefix("my-work.2"))
+}
@@ -0,0 +1,2 @@
+// Package environment exposes credentials kept in environment variables, e.g. FSMAIL_USERNAME and FSMAIL_PASSWORD
+package environment
@@ -0,0 +1,20 @@
+package environment
+
+import (
+	"fmt"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+)
+
+// Client exposes credentials kept in environment variables named after Prefix and the key of the credential
+type Client struct {
+	// Prefix starts the name of every variable, e.g. FSMAIL or FSMAIL_WORK
+	Prefix string
+	// LookupEnv looks up a variable. Defaults to os.LookupEnv
+	LookupEnv func(string) (string, bool)
+}
+
+// DefaultPrefix starts the variables of the unnamed account
+const DefaultPrefix = "FSMAIL"
+
+var errNotSet = fmt.Errorf("not set: %w", credentials.ErrNotFound)
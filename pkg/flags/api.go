@@ -0,0 +1,111 @@
+package flags
+
+import "strings"
+
+// FromIMAP knows how to convert IMAP system flags into local flags. Flags that are not synchronized are ignored
+func FromIMAP(imapFlagList []string) []string {
+	result := make([]string, 0, len(imapFlagList))
+
+	for _, flag := range supported {
+		for _, imapFlag := range imapFlagList {
+			if strings.EqualFold(imapFlags[flag], imapFlag) {
+				result = append(result, flag)
+
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// ToIMAP knows how to convert local flags into IMAP system flags
+func ToIMAP(flagList []string) []string {
+	result := make([]string, 0, len(flagList))
+
+	for _, flag := range Normalize(flagList) {
+		result = append(result, imapFlags[flag])
+	}
+
+	return result
+}
+
+// Normalize knows how to turn a list of flags into the canonical form. Unknown flags and duplicates are removed, and
+// the remaining flags are ordered as seen, flagged, answered
+func Normalize(flagList []string) []string {
+	result := make([]string, 0, len(flagList))
+
+	for _, flag := range supported {
+		if contains(flagList, flag) {
+			result = append(result, flag)
+		}
+	}
+
+	return result
+}
+
+// Merge knows how to combine local and remote changes made since the base flags were last synchronized. Every flag is
+// merged on its own, and the side that changed it since the last synchronization wins. Since a flag is either set or
+// not, both sides changing the same flag means they agree on the outcome, so the merge never conflicts
+func Merge(base, local, remote []string) []string {
+	result := make([]string, 0, len(supported))
+
+	for _, flag := range supported {
+		inBase := contains(base, flag)
+		inLocal := contains(local, flag)
+		inRemote := contains(remote, flag)
+
+		keep := inRemote
+		if inLocal != inBase {
+			keep = inLocal
+		}
+
+		if keep {
+			result = append(result, flag)
+		}
+	}
+
+	return result
+}
+
+// Diff knows how to calculate which flags must be added and removed to turn from into to
+func Diff(from, to []string) (added []string, removed []string) {
+	for _, flag := range supported {
+		inFrom := contains(from, flag)
+		inTo := contains(to, flag)
+
+		switch {
+		case inTo && !inFrom:
+			added = append(added, flag)
+		case inFrom && !inTo:
+			removed = append(removed, flag)
+		}
+	}
+
+	return added, removed
+}
+
+// Parse knows how to read a comma separated list of flags
+func Parse(raw string) []string {
+	result := make([]string, 0)
+
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return Normalize(result)
+}
+
+func contains(list []string, item string) bool {
+	for _, candidate := range list {
+		if candidate == item {
+			return true
+		}
+	}
+
+	return false
+}
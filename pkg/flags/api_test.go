@@ -0,0 +1,73 @@
+package flags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withBase    []string
+		withLocal   []string
+		withRemote  []string
+		expectFlags []string
+	}{
+		{
+			name:        "Should keep flags when nothing changed",
+			withBase:    []string{Seen},
+			withLocal:   []string{Seen},
+			withRemote:  []string{Seen},
+			expectFlags: []string{Seen},
+		},
+		{
+			name:        "Should apply a remote change",
+			withBase:    []string{},
+			withLocal:   []string{},
+			withRemote:  []string{Seen},
+			expectFlags: []string{Seen},
+		},
+		{
+			name:        "Should apply a local change",
+			withBase:    []string{Seen},
+			withLocal:   []string{Seen, Flagged},
+			withRemote:  []string{Seen},
+			expectFlags: []string{Seen, Flagged},
+		},
+		{
+			name:        "Should apply a local removal",
+			withBase:    []string{Seen, Flagged},
+			withLocal:   []string{Seen},
+			withRemote:  []string{Seen, Flagged},
+			expectFlags: []string{Seen},
+		},
+		{
+			name:        "Should combine changes to different flags",
+			withBase:    []string{Flagged},
+			withLocal:   []string{},
+			withRemote:  []string{Seen, Flagged, Answered},
+			expectFlags: []string{Seen, Answered},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectFlags, Merge(tc.withBase, tc.withLocal, tc.withRemote))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, []string{Seen, Flagged}, Parse("Flagged, seen, unknown, seen"))
+	assert.Equal(t, []string{}, Parse(""))
+}
+
+func TestIMAPConversion(t *testing.T) {
+	assert.Equal(t, []string{Seen, Answered}, FromIMAP([]string{`\Answered`, `\Recent`, `\Seen`}))
+	assert.Equal(t, []string{`\Seen`, `\Flagged`}, ToIMAP([]string{Flagged, Seen}))
+}
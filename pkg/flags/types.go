@@ -0,0 +1,19 @@
+package flags
+
+const (
+	// Seen marks a message as read
+	Seen = "seen"
+	// Flagged marks a message as important
+	Flagged = "flagged"
+	// Answered marks a message as replied to
+	Answered = "answered"
+)
+
+// supported lists the synchronized flags in the order they are written
+var supported = []string{Seen, Flagged, Answered}
+
+var imapFlags = map[string]string{
+	Seen:     `\Seen`,
+	Flagged:  `\Flagged`,
+	Answered: `\Answered`,
+}
@@ -0,0 +1,90 @@
+package folders
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ToDirectory knows how to convert an IMAP mailbox name into a relative directory path. Every level of the mailbox
+// hierarchy becomes a nested directory, and INBOX is mapped to the local inbox directory
+func ToDirectory(name string, delimiter string) string {
+	segments := split(name, delimiter)
+
+	if strings.EqualFold(segments[0], inboxName) {
+		segments[0] = InboxDirectory
+	}
+
+	for index, segment := range segments {
+		segments[index] = sanitizeSegment(segment)
+	}
+
+	return filepath.Join(segments...)
+}
+
+// ToPattern knows how to convert an IMAP mailbox name into the form glob patterns are matched against, where every
+// level of the hierarchy is separated by a slash
+func ToPattern(name string, delimiter string) string {
+	return strings.Join(split(name, delimiter), "/")
+}
+
+// Match knows how to decide if a mailbox should be synchronized based on include and exclude glob patterns. An empty
+// include list includes every mailbox. Exclusion takes precedence over inclusion
+func Match(name string, delimiter string, include []string, exclude []string) (bool, error) {
+	candidate := ToPattern(name, delimiter)
+
+	for _, pattern := range exclude {
+		matched, err := path.Match(pattern, candidate)
+		if err != nil {
+			return false, fmt.Errorf("matching exclude pattern %s: %w", pattern, err)
+		}
+
+		if matched {
+			return false, nil
+		}
+	}
+
+	if len(include) == 0 {
+		return true, nil
+	}
+
+	for _, pattern := range include {
+		matched, err := path.Match(pattern, candidate)
+		if err != nil {
+			return false, fmt.Errorf("matching include pattern %s: %w", pattern, err)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func split(name string, delimiter string) []string {
+	if delimiter == "" {
+		return []string{name}
+	}
+
+	return strings.Split(name, delimiter)
+}
+
+func sanitizeSegment(segment string) string {
+	segment = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		default:
+			return r
+		}
+	}, segment)
+
+	switch segment {
+	case "", ".", "..":
+		return strings.Repeat("_", len(segment)+1)
+	default:
+		return segment
+	}
+}
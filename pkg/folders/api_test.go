@@ -0,0 +1,117 @@
+package folders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDirectory(t *testing.T) {
+	testCases := []struct {
+		name            string
+		withMailbox     string
+		withDelimiter   string
+		expectDirectory string
+	}{
+		{
+			name:            "Should map INBOX to the inbox directory",
+			withMailbox:     "INBOX",
+			withDelimiter:   "/",
+			expectDirectory: "inbox",
+		},
+		{
+			name:            "Should nest children of INBOX inside the inbox directory",
+			withMailbox:     "INBOX.Receipts",
+			withDelimiter:   ".",
+			expectDirectory: "inbox/Receipts",
+		},
+		{
+			name:            "Should nest hierarchy levels",
+			withMailbox:     "Work/Invoices",
+			withDelimiter:   "/",
+			expectDirectory: "Work/Invoices",
+		},
+		{
+			name:            "Should respect a non slash delimiter",
+			withMailbox:     "Lists.golang",
+			withDelimiter:   ".",
+			expectDirectory: "Lists/golang",
+		},
+		{
+			name:            "Should not treat slashes as hierarchy when the delimiter differs",
+			withMailbox:     "Lists.a/b",
+			withDelimiter:   ".",
+			expectDirectory: "Lists/a_b",
+		},
+		{
+			name:            "Should prevent escaping the work directory",
+			withMailbox:     "../..",
+			withDelimiter:   "/",
+			expectDirectory: "___/___",
+		},
+		{
+			name:            "Should handle a flat hierarchy",
+			withMailbox:     "Archive/2022",
+			withDelimiter:   "",
+			expectDirectory: "Archive_2022",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectDirectory, ToDirectory(tc.withMailbox, tc.withDelimiter))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withMailbox string
+		withInclude []string
+		withExclude []string
+		expectMatch bool
+	}{
+		{
+			name:        "Should include everything without patterns",
+			withMailbox: "Archive",
+			expectMatch: true,
+		},
+		{
+			name:        "Should include matching mailboxes",
+			withMailbox: "Lists.golang",
+			withInclude: []string{"INBOX", "Lists/*"},
+			expectMatch: true,
+		},
+		{
+			name:        "Should skip mailboxes not included",
+			withMailbox: "Archive",
+			withInclude: []string{"INBOX", "Lists/*"},
+			expectMatch: false,
+		},
+		{
+			name:        "Should let exclusion take precedence",
+			withMailbox: "Lists.spam",
+			withInclude: []string{"Lists/*"},
+			withExclude: []string{"Lists/spam"},
+			expectMatch: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			matched, err := Match(tc.withMailbox, ".", tc.withInclude, tc.withExclude)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectMatch, matched)
+		})
+	}
+}
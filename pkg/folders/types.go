@@ -0,0 +1,6 @@
+package folders
+
+// InboxDirectory defines the name of the local directory INBOX is mirrored into
+const InboxDirectory = "inbox"
+
+const inboxName = "INBOX"
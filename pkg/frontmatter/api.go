@@ -0,0 +1,201 @@
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse knows how to split a message file into its header block and body. The header block is enclosed by lines
+// containing only ---. The body is everything after the blank line following the header block.
+//
+// Header blocks using lower case keys, indented lists or nested fields are read as YAML front matter. Anything else is
+// read as a list of Key: value lines, where a line starting with whitespace continues the value of the previous field
+func Parse(content io.Reader) (Document, error) {
+	raw, err := io.ReadAll(content)
+	if err != nil {
+		return Document{}, fmt.Errorf("reading content: %w", err)
+	}
+
+	lines := strings.Split(string(raw), "\n")
+	start := 0
+
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+
+	if start == len(lines) || !isDivider(lines[start]) {
+		return Document{}, errMissingHeader
+	}
+
+	end := start + 1
+
+	for end < len(lines) && !isDivider(lines[end]) {
+		end++
+	}
+
+	if end == len(lines) {
+		return Document{}, errUnterminatedHeader
+	}
+
+	var doc Document
+
+	block := lines[start+1 : end]
+
+	if node, ok := yamlMapping(block); ok {
+		doc, err = parseYAML(node)
+	} else {
+		doc, err = parseHeaders(block)
+	}
+
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc.Body = strings.Join(lines[end+1:], "\n")
+	doc.Body = strings.TrimPrefix(strings.TrimPrefix(doc.Body, "\r"), "\n")
+
+	return doc, nil
+}
+
+// parseHeaders knows how to parse a header block of Key: value lines
+func parseHeaders(lines []string) (Document, error) {
+	doc := Document{Format: FormatHeaders, Headers: make([]Header, 0)}
+
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		switch {
+		case line == "":
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			if len(doc.Headers) == 0 {
+				return Document{}, fmt.Errorf("%w: %s", errInvalidHeaderLine, line)
+			}
+
+			last := &doc.Headers[len(doc.Headers)-1]
+			last.Value += "\n" + line[1:]
+		default:
+			key, value, found := strings.Cut(line, ":")
+			if !found || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+				return Document{}, fmt.Errorf("%w: %s", errInvalidHeaderLine, line)
+			}
+
+			doc.Headers = append(doc.Headers, Header{Key: key, Value: strings.TrimSpace(value)})
+		}
+	}
+
+	return doc, nil
+}
+
+// Serialize knows how to turn a document into the content of a message file, using the format of the document.
+// Parsing the result returns the same document
+func Serialize(doc Document) []byte {
+	buf := bytes.Buffer{}
+
+	buf.WriteString(divider + "\n")
+
+	if doc.Format == FormatYAML {
+		buf.Write(serializeYAML(doc))
+	} else {
+		serializeHeaders(&buf, append(append([]Header{}, doc.Headers...), doc.Custom...))
+	}
+
+	buf.WriteString(divider + "\n\n")
+	buf.WriteString(doc.Body)
+
+	return buf.Bytes()
+}
+
+func serializeHeaders(buf *bytes.Buffer, headers []Header) {
+	for _, header := range headers {
+		lines := strings.Split(header.Value, "\n")
+
+		buf.WriteString(header.Key + ":")
+
+		if lines[0] != "" {
+			buf.WriteString(" " + lines[0])
+		}
+
+		buf.WriteString("\n")
+
+		for _, line := range lines[1:] {
+			buf.WriteString(" " + line + "\n")
+		}
+	}
+}
+
+// ParseFormat knows how to validate the name of a format
+func ParseFormat(raw string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(raw))); format {
+	case FormatHeaders, FormatYAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w %q, expected %s or %s", errUnknownFormat, raw, FormatHeaders, FormatYAML)
+	}
+}
+
+// Get returns the value of the first field named key, or an empty string. Keys are case insensitive
+func (d Document) Get(key string) string {
+	for _, header := range d.Headers {
+		if strings.EqualFold(header.Key, key) {
+			return header.Value
+		}
+	}
+
+	return ""
+}
+
+// Values returns the values of every field named key
+func (d Document) Values(key string) []string {
+	result := make([]string, 0)
+
+	for _, header := range d.Headers {
+		if strings.EqualFold(header.Key, key) {
+			result = append(result, header.Value)
+		}
+	}
+
+	return result
+}
+
+// Add appends a field to the header block
+func (d *Document) Add(key string, value string) {
+	d.Headers = append(d.Headers, Header{Key: key, Value: value})
+}
+
+// Set replaces the value of the first field named key and removes any others. The field is appended when missing
+func (d *Document) Set(key string, value string) {
+	for index, header := range d.Headers {
+		if strings.EqualFold(header.Key, key) {
+			d.Headers[index].Value = value
+			d.Headers = append(d.Headers[:index+1], without(d.Headers[index+1:], key)...)
+
+			return
+		}
+	}
+
+	d.Add(key, value)
+}
+
+// Del removes every field named key
+func (d *Document) Del(key string) {
+	d.Headers = without(d.Headers, key)
+}
+
+func without(headers []Header, key string) []Header {
+	result := make([]Header, 0, len(headers))
+
+	for _, header := range headers {
+		if !strings.EqualFold(header.Key, key) {
+			result = append(result, header)
+		}
+	}
+
+	return result
+}
+
+func isDivider(line string) bool {
+	return strings.TrimRight(line, " \t\r") == divider
+}
@@ -0,0 +1,370 @@
+package frontmatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		withDoc Document
+	}{
+		{
+			name: "Should round trip a simple message",
+			withDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "From", Value: "me@example.com"},
+					{Key: "To", Value: "you@example.com"},
+					{Key: "Subject", Value: "Re: Hello"},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name: "Should round trip repeated and custom headers",
+			withDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: "you@example.com"},
+					{Key: "Attach", Value: "invoice.pdf"},
+					{Key: "Attach", Value: "receipts/taxi.png"},
+					{Key: "X-Priority", Value: "1"},
+				},
+				Body: "Mock content\n",
+			},
+		},
+		{
+			name: "Should round trip multi-line values",
+			withDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: "Jane Doe <jane@example.com>,\njohn@example.com"},
+					{Key: "References", Value: "<first@example.com>\n<second@example.com>"},
+					{Key: "X-Note", Value: "\n  indented\n\nafter a blank line"},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name: "Should round trip bodies looking like headers",
+			withDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "Subject", Value: "Tricky"},
+				},
+				Body: "\n---\nSubject: not a header\n---\n",
+			},
+		},
+		{
+			name: "Should round trip empty values and bodies",
+			withDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: ""},
+					{Key: "Subject", Value: ""},
+				},
+				Body: "",
+			},
+		},
+		{
+			name: "Should round trip YAML front matter",
+			withDoc: Document{
+				Format: FormatYAML,
+				Headers: []Header{
+					{Key: "from", Value: "me@example.com"},
+					{Key: "to", Value: "Jane Doe <jane@example.com>", List: true},
+					{Key: "to", Value: "john@example.com", List: true},
+					{Key: "cc", Value: "jim@example.com", List: true},
+					{Key: "subject", Value: "Re: Issue #5"},
+					{Key: "draft", Value: "true"},
+					{Key: "tags", Value: "work", List: true},
+					{Key: "tags", Value: "- not a list", List: true},
+				},
+				Custom: []Header{
+					{Key: "X-Priority", Value: "1"},
+					{Key: "X-Note", Value: "first line\nsecond line"},
+					{Key: "X-Padded", Value: "\n  indented\n"},
+				},
+				Body: "Mock content\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			raw := Serialize(tc.withDoc)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), raw)
+
+			doc, err := Parse(bytes.NewReader(raw))
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.withDoc, doc)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withContent string
+		expectDoc   Document
+		expectError error
+	}{
+		{
+			name:        "Should accept loosely formatted files",
+			withContent: "\n---\r\nTo:you@example.com  \r\n\r\nSubject:   Hello\r\n---  \r\n\r\nMock content\r\n",
+			expectDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: "you@example.com"},
+					{Key: "Subject", Value: "Hello"},
+				},
+				Body: "Mock content\r\n",
+			},
+		},
+		{
+			name:        "Should accept a body without a blank line in front",
+			withContent: "---\nSubject: Hello\n---\nMock content",
+			expectDoc: Document{
+				Format:  FormatHeaders,
+				Headers: []Header{{Key: "Subject", Value: "Hello"}},
+				Body:    "Mock content",
+			},
+		},
+		{
+			name:        "Should read YAML front matter",
+			withContent: "---\nto:\n  - jane@example.com\n  - john@example.com\nsubject: Hello\nflags: [seen]\nheaders:\n  X-Priority: 1\n---\n\nMock content",
+			expectDoc: Document{
+				Format: FormatYAML,
+				Headers: []Header{
+					{Key: "to", Value: "jane@example.com", List: true},
+					{Key: "to", Value: "john@example.com", List: true},
+					{Key: "subject", Value: "Hello"},
+					{Key: "flags", Value: "seen", List: true},
+				},
+				Custom: []Header{{Key: "X-Priority", Value: "1"}},
+				Body:   "Mock content",
+			},
+		},
+		{
+			name:        "Should read header lists that happen to be valid YAML as header lists",
+			withContent: "---\nTo: jane@example.com\nSubject: Issue #5\n---\n\nMock content",
+			expectDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: "jane@example.com"},
+					{Key: "Subject", Value: "Issue #5"},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name:        "Should read bracketed values in header lists as they are",
+			withContent: "---\nTo: jane@example.com\nSubject: [SPAM] offer\n---\n\nMock content",
+			expectDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "To", Value: "jane@example.com"},
+					{Key: "Subject", Value: "[SPAM] offer"},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name:        "Should read braced values in header lists as they are",
+			withContent: "---\nSubject: {draft}\nX-Label: [SPAM]\n---\n\nMock content",
+			expectDoc: Document{
+				Format: FormatHeaders,
+				Headers: []Header{
+					{Key: "Subject", Value: "{draft}"},
+					{Key: "X-Label", Value: "[SPAM]"},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name:        "Should read indented lists with capitalized keys as YAML front matter",
+			withContent: "---\nTo:\n  - jane@example.com\n  - john@example.com\n---\n\nMock content",
+			expectDoc: Document{
+				Format: FormatYAML,
+				Headers: []Header{
+					{Key: "To", Value: "jane@example.com", List: true},
+					{Key: "To", Value: "john@example.com", List: true},
+				},
+				Body: "Mock content",
+			},
+		},
+		{
+			name:        "Should fail on nested YAML fields",
+			withContent: "---\nto: jane@example.com\nextra:\n  nested: true\n---\n",
+			expectError: errUnsupportedValue,
+		},
+		{
+			name:        "Should fail without a header block",
+			withContent: "Mock content",
+			expectError: errMissingHeader,
+		},
+		{
+			name:        "Should fail on an unterminated header block",
+			withContent: "---\nSubject: Hello\n",
+			expectError: errUnterminatedHeader,
+		},
+		{
+			name:        "Should fail on invalid header lines",
+			withContent: "---\nnot a header\n---\n",
+			expectError: errInvalidHeaderLine,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := Parse(strings.NewReader(tc.withContent))
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectDoc, doc)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	headers := Document{Format: FormatHeaders}
+	headers.AddList("Attachments", ", ", []string{"a.pdf", "b.png"})
+
+	assert.Equal(t, []Header{{Key: "Attachments", Value: "a.pdf, b.png"}}, headers.Headers)
+	assert.Equal(t, []string{"a.pdf", "b.png"}, headers.List("attachments"))
+
+	yamlDoc := Document{Format: FormatYAML}
+	yamlDoc.AddList("Attachments", ", ", []string{"a, b.pdf", "c.png"})
+
+	assert.Equal(t, []Header{
+		{Key: "Attachments", Value: "a, b.pdf", List: true},
+		{Key: "Attachments", Value: "c.png", List: true},
+	}, yamlDoc.Headers)
+	assert.Equal(t, []string{"a, b.pdf", "c.png"}, yamlDoc.List("attachments"))
+}
+
+func TestSet(t *testing.T) {
+	doc := Document{
+		Headers: []Header{
+			{Key: "To", Value: "you@example.com"},
+			{Key: "Flags", Value: "seen"},
+			{Key: "Subject", Value: "Hello"},
+			{Key: "flags", Value: "flagged"},
+		},
+	}
+
+	doc.Set("Flags", "answered")
+	doc.Set("Cc", "jane@example.com")
+
+	assert.Equal(t, []Header{
+		{Key: "To", Value: "you@example.com"},
+		{Key: "Flags", Value: "answered"},
+		{Key: "Subject", Value: "Hello"},
+		{Key: "Cc", Value: "jane@example.com"},
+	}, doc.Headers)
+
+	doc.Del("FLAGS")
+
+	assert.Equal(t, "", doc.Get("Flags"))
+	assert.Equal(t, "Hello", doc.Get("subject"))
+}
+
+func TestEnvelope(t *testing.T) {
+	testCases := []struct {
+		name           string
+		withFormat     Format
+		expectHeaders  []Header
+		expectCustom   []Header
+		expectEnvelope Envelope
+	}{
+		{
+			name:       "Should write custom headers apart from the other fields in header lists",
+			withFormat: FormatHeaders,
+			expectHeaders: []Header{
+				{Key: FromKey, Value: "Jane Doe <jane@example.com>"},
+				{Key: ToKey, Value: "john@example.com, jim@example.com"},
+				{Key: SubjectKey, Value: "Hello"},
+				{Key: MessageIDKey, Value: "<second@example.com>"},
+				{Key: ReferencesKey, Value: "<first@example.com>"},
+			},
+			expectCustom: []Header{{Key: "X-Priority", Value: "1"}},
+		},
+		{
+			name:       "Should write lists as YAML lists",
+			withFormat: FormatYAML,
+			expectHeaders: []Header{
+				{Key: FromKey, Value: "Jane Doe <jane@example.com>"},
+				{Key: ToKey, Value: "john@example.com", List: true},
+				{Key: ToKey, Value: "jim@example.com", List: true},
+				{Key: SubjectKey, Value: "Hello"},
+				{Key: MessageIDKey, Value: "<second@example.com>"},
+				{Key: ReferencesKey, Value: "<first@example.com>", List: true},
+			},
+			expectCustom: []Header{{Key: "X-Priority", Value: "1"}},
+		},
+	}
+
+	envelope := Envelope{
+		From:       "Jane Doe <jane@example.com>",
+		To:         []string{"john@example.com", "jim@example.com"},
+		Subject:    "Hello",
+		MessageID:  "second@example.com",
+		References: []string{"first@example.com"},
+		Headers:    []Header{{Key: "X-Priority", Value: "1"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := Document{Format: tc.withFormat}
+			doc.AddEnvelope(envelope)
+
+			assert.Equal(t, tc.expectHeaders, doc.Headers)
+			assert.Equal(t, tc.expectCustom, doc.Custom)
+
+			parsed, err := Parse(bytes.NewReader(Serialize(doc)))
+			assert.NoError(t, err)
+
+			result, err := parsed.Envelope()
+			assert.NoError(t, err)
+			assert.Equal(t, envelope, result)
+		})
+	}
+}
+
+func TestEnvelopeKnownKeys(t *testing.T) {
+	doc, err := Parse(strings.NewReader("---\nTo: jane@example.com\nFlags: seen\nAttach: a.pdf\nX-Priority: 1\n---\n"))
+	assert.NoError(t, err)
+
+	envelope, err := doc.Envelope()
+	assert.NoError(t, err)
+
+	// Fields of received message files and outbox files are never custom headers, whichever file they are found in
+	assert.Equal(t, []Header{{Key: "X-Priority", Value: "1"}}, envelope.Headers)
+}
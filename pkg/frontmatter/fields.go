@@ -0,0 +1,123 @@
+package frontmatter
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/deifyed/fsmail/pkg/messageid"
+)
+
+// DateLayout is the layout of dates in the header block
+const DateLayout = time.RFC1123Z
+
+// AddNonEmpty appends a field to the header block unless value is empty
+func (d *Document) AddNonEmpty(key string, value string) {
+	if value != "" {
+		d.Add(key, value)
+	}
+}
+
+// AddList appends a list of values to the header block. YAML front matter gets a list, header lists a single field with
+// the values joined by separator
+func (d *Document) AddList(key string, separator string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	if d.Format != FormatYAML {
+		d.Add(key, strings.Join(values, separator))
+
+		return
+	}
+
+	for _, value := range values {
+		d.Headers = append(d.Headers, Header{Key: key, Value: value, List: true})
+	}
+}
+
+// Addresses returns the addresses in every field named key. Every field can contain a comma separated list
+func (d Document) Addresses(key string) ([]string, error) {
+	values := d.Values(key)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return addresses.ParseList(strings.Join(unfold(values), ", "))
+}
+
+// List returns the items of the comma separated lists in every field named key. Items of YAML lists are taken as they
+// are. Returns nil when there is no such field
+func (d Document) List(key string) []string {
+	values := d.Values(key)
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0)
+
+	for _, value := range unfold(values) {
+		items := []string{value}
+		if d.Format != FormatYAML {
+			items = strings.Split(value, ",")
+		}
+
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+
+			if item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+
+	return result
+}
+
+// MessageIDs returns the message IDs in every field named key, without angle brackets. Returns nil when there is no
+// such field
+func (d Document) MessageIDs(key string) []string {
+	values := d.Values(key)
+	if len(values) == 0 {
+		return nil
+	}
+
+	return messageid.ParseList(strings.Join(unfold(values), " "))
+}
+
+// Date returns the date in the first field named key. Returns the zero time when the field is missing
+func (d Document) Date(key string) (time.Time, error) {
+	value := strings.TrimSpace(d.Get(key))
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	date, err := mail.ParseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %q: %w", value, err)
+	}
+
+	return date, nil
+}
+
+// FormatDate knows how to format a date for the header block. Returns an empty string for the zero time
+func FormatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.Format(DateLayout)
+}
+
+// unfold joins the lines of multi-line values
+func unfold(values []string) []string {
+	result := make([]string, len(values))
+
+	for index, value := range values {
+		result[index] = strings.ReplaceAll(value, "\n", " ")
+	}
+
+	return result
+}
@@ -0,0 +1,152 @@
+package frontmatter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/messageid"
+)
+
+// Names of the fields in the header block of message files
+const (
+	FromKey       = "From"
+	ToKey         = "To"
+	CcKey         = "Cc"
+	BccKey        = "Bcc"
+	ReplyToKey    = "Reply-To"
+	SubjectKey    = "Subject"
+	DateKey       = "Date"
+	MessageIDKey  = "Message-ID"
+	InReplyToKey  = "In-Reply-To"
+	ReferencesKey = "References"
+	// TagsKey names labels added by the user. They are local and never sent
+	TagsKey = "Tags"
+	// FlagsKey and AttachmentsKey are written to received message files
+	FlagsKey       = "Flags"
+	AttachmentsKey = "Attachments"
+	// FormatKey, ContentTypeKey, AttachKey and DraftKey are read from outbox files
+	FormatKey      = "Format"
+	ContentTypeKey = "Content-Type"
+	AttachKey      = "Attach"
+	DraftKey       = "Draft"
+)
+
+// knownKeys contains the fields with a meaning to fsmail. Every other field is a custom header
+var knownKeys = []string{
+	FromKey, ToKey, CcKey, BccKey, ReplyToKey, SubjectKey, DateKey, MessageIDKey, InReplyToKey, ReferencesKey,
+	TagsKey, FlagsKey, AttachmentsKey, FormatKey, ContentTypeKey, AttachKey, DraftKey,
+}
+
+const (
+	listSeparator      = ", "
+	messageIDSeparator = " "
+)
+
+// Envelope contains the fields shared by received message files and outbox files
+type Envelope struct {
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo []string
+	Subject string
+	Date    time.Time
+	// MessageID, InReplyTo and References contain message IDs without angle brackets
+	MessageID  string
+	InReplyTo  string
+	References []string
+	// Headers contains the custom headers, in order
+	Headers []Header
+}
+
+// Envelope knows how to read the fields shared by every message file
+func (d Document) Envelope() (Envelope, error) {
+	var err error
+
+	envelope := Envelope{
+		From:       d.Get(FromKey),
+		Subject:    d.Get(SubjectKey),
+		MessageID:  messageid.Parse(d.Get(MessageIDKey)),
+		InReplyTo:  messageid.Parse(d.Get(InReplyToKey)),
+		References: d.MessageIDs(ReferencesKey),
+	}
+
+	for _, field := range []struct {
+		key    string
+		target *[]string
+	}{
+		{key: ToKey, target: &envelope.To},
+		{key: CcKey, target: &envelope.Cc},
+		{key: BccKey, target: &envelope.Bcc},
+		{key: ReplyToKey, target: &envelope.ReplyTo},
+	} {
+		*field.target, err = d.Addresses(field.key)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("parsing %s: %w", field.key, err)
+		}
+	}
+
+	envelope.Date, err = d.Date(DateKey)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("parsing %s: %w", DateKey, err)
+	}
+
+	// Header lists mix custom headers with the other fields, while YAML front matter nests them
+	if d.Format != FormatYAML {
+		for _, header := range d.Headers {
+			if !isKnownKey(header.Key) {
+				envelope.Headers = append(envelope.Headers, header)
+			}
+		}
+	}
+
+	envelope.Headers = append(envelope.Headers, d.Custom...)
+
+	return envelope, nil
+}
+
+// AddEnvelope knows how to write the fields shared by every message file. Custom headers are written after the other
+// fields
+func (d *Document) AddEnvelope(envelope Envelope) {
+	d.AddNonEmpty(FromKey, envelope.From)
+	d.AddList(ToKey, listSeparator, envelope.To)
+
+	// Header lists always carry the To field, to make it easy to fill in
+	if len(envelope.To) == 0 && d.Format != FormatYAML {
+		d.Add(ToKey, "")
+	}
+
+	d.AddList(CcKey, listSeparator, envelope.Cc)
+	d.AddList(BccKey, listSeparator, envelope.Bcc)
+	d.AddList(ReplyToKey, listSeparator, envelope.ReplyTo)
+	d.Add(SubjectKey, envelope.Subject)
+	d.AddNonEmpty(DateKey, FormatDate(envelope.Date))
+	d.AddNonEmpty(MessageIDKey, messageid.Format(envelope.MessageID))
+	d.AddNonEmpty(InReplyToKey, messageid.Format(envelope.InReplyTo))
+	d.AddList(ReferencesKey, messageIDSeparator, formatMessageIDs(envelope.References))
+
+	d.Custom = append(d.Custom, envelope.Headers...)
+}
+
+func formatMessageIDs(ids []string) []string {
+	result := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		if id != "" {
+			result = append(result, messageid.Format(id))
+		}
+	}
+
+	return result
+}
+
+func isKnownKey(key string) bool {
+	for _, known := range knownKeys {
+		if strings.EqualFold(key, known) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package frontmatter
+
+import "errors"
+
+// Header is a single field in the header block of a message file
+type Header struct {
+	Key string
+	// Value contains the value of the field. Lines after the first are written as indented continuation lines
+	Value string
+	// List marks an item of a YAML list. Keys occurring several times are written as a list regardless
+	List bool
+}
+
+// Document is a message file split into its header block and its body
+type Document struct {
+	// Format describes how the header block is written
+	Format Format
+	// Headers contains the fields of the header block in order. Keys can repeat
+	Headers []Header
+	// Custom contains additional message headers. YAML front matter keeps them apart from the other fields, nested
+	// under headers. Header lists have no such distinction, custom headers are written after the other fields and
+	// parsed into Headers
+	Custom []Header
+	Body   string
+}
+
+// Format describes how the header block of a message file is written
+type Format string
+
+const (
+	// FormatHeaders writes every field as a Key: value line
+	FormatHeaders Format = "headers"
+	// FormatYAML writes the header block as YAML front matter, with lists for list items and fields occurring several
+	// times
+	FormatYAML Format = "yaml"
+)
+
+const (
+	divider = "---"
+
+	// customHeadersKey is the YAML field containing the custom headers
+	customHeadersKey = "headers"
+	yamlIndentation  = 2
+)
+
+var (
+	errMissingHeader      = errors.New("missing header block")
+	errUnterminatedHeader = errors.New("unterminated header block")
+	errInvalidHeaderLine  = errors.New("invalid header line")
+	errUnsupportedValue   = errors.New("unsupported value")
+	errUnknownFormat      = errors.New("unknown format")
+)
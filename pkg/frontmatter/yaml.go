@@ -0,0 +1,179 @@
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlMapping returns the YAML mapping in a header block, if the block is meant as YAML front matter. A block of
+// Key: value lines is often valid YAML as well, so the block only counts as YAML when it uses lower case keys, or
+// indented lists or nested fields, which header lists never do. Values such as [SPAM] offer or {draft} read as flow
+// collections in YAML, but are plain values in header lists
+func yamlMapping(lines []string) (*yaml.Node, bool) {
+	var node yaml.Node
+
+	err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &node)
+	if err != nil || node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
+		return nil, false
+	}
+
+	mapping := node.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, false
+	}
+
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		key, value := mapping.Content[index], resolve(mapping.Content[index+1])
+
+		if startsWithLower(key.Value) || value.Kind != yaml.ScalarNode && value.Style&yaml.FlowStyle == 0 {
+			return mapping, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseYAML knows how to turn a YAML mapping into a document. Lists turn into a field per item
+func parseYAML(mapping *yaml.Node) (Document, error) {
+	doc := Document{Format: FormatYAML, Headers: make([]Header, 0)}
+
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		key, value := mapping.Content[index].Value, resolve(mapping.Content[index+1])
+
+		if strings.EqualFold(key, customHeadersKey) && value.Kind == yaml.MappingNode {
+			for nested := 0; nested+1 < len(value.Content); nested += 2 {
+				headers, err := yamlHeaders(value.Content[nested].Value, resolve(value.Content[nested+1]))
+				if err != nil {
+					return Document{}, err
+				}
+
+				doc.Custom = append(doc.Custom, headers...)
+			}
+
+			continue
+		}
+
+		headers, err := yamlHeaders(key, value)
+		if err != nil {
+			return Document{}, err
+		}
+
+		doc.Headers = append(doc.Headers, headers...)
+	}
+
+	return doc, nil
+}
+
+func yamlHeaders(key string, value *yaml.Node) ([]Header, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return []Header{{Key: key, Value: value.Value}}, nil
+	case yaml.SequenceNode:
+		headers := make([]Header, 0, len(value.Content))
+
+		for _, item := range value.Content {
+			item = resolve(item)
+
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%w: nested list in %s", errUnsupportedValue, key)
+			}
+
+			headers = append(headers, Header{Key: key, Value: item.Value, List: true})
+		}
+
+		return headers, nil
+	default:
+		return nil, fmt.Errorf("%w: nested fields in %s", errUnsupportedValue, key)
+	}
+}
+
+// serializeYAML knows how to write the header block of a document as YAML. Keys are written in lower case, custom
+// headers keep their spelling
+func serializeYAML(doc Document) []byte {
+	mapping := yamlFields(doc.Headers, strings.ToLower)
+
+	if len(doc.Custom) > 0 {
+		mapping.Content = append(mapping.Content,
+			yamlScalar(customHeadersKey),
+			yamlFields(doc.Custom, func(key string) string { return key }),
+		)
+	}
+
+	if len(mapping.Content) == 0 {
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndentation)
+
+	// Encoding a tree of scalars, lists and mappings into a buffer does not fail
+	_ = encoder.Encode(mapping)
+	_ = encoder.Close()
+
+	return buf.Bytes()
+}
+
+// yamlFields groups headers by key into a mapping. Keys occurring once map to a scalar unless marked as a list item
+func yamlFields(headers []Header, formatKey func(string) string) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	fields := make(map[string]*yaml.Node)
+
+	for _, header := range headers {
+		key := formatKey(header.Key)
+
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			field = yamlScalar(header.Value)
+			if header.List {
+				field = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{field}}
+			}
+
+			fields[strings.ToLower(key)] = field
+
+			mapping.Content = append(mapping.Content, yamlScalar(key), field)
+
+			continue
+		}
+
+		if field.Kind == yaml.ScalarNode {
+			*field = yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{yamlScalar(field.Value)}}
+		}
+
+		field.Content = append(field.Content, yamlScalar(header.Value))
+	}
+
+	return mapping
+}
+
+// yamlScalar creates a scalar node. Values with surrounding whitespace are quoted, as block scalars lose it
+func yamlScalar(value string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+
+	if value != strings.TrimSpace(value) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+
+	return node
+}
+
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	return node
+}
+
+func startsWithLower(key string) bool {
+	for _, r := range key {
+		return unicode.IsLower(r)
+	}
+
+	return false
+}
@@ -6,126 +6,178 @@ import (
 	"io"
 	"path"
 	"strings"
-	"text/template"
 
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/deifyed/fsmail/pkg/messageid"
 	"github.com/spf13/afero"
 )
 
-type header struct {
-	From    string
-	To      string
-	Subject string
-}
+const listSeparator = ", "
 
-func extractHeader(content io.Reader) (header, error) {
-	raw, err := io.ReadAll(content)
+// documentToMessage turns a parsed message file into a message. It returns the paths of the attachments listed in the
+// header separately, as reading them requires the location of the file
+func documentToMessage(doc frontmatter.Document) (Message, []string, error) {
+	envelope, err := doc.Envelope()
 	if err != nil {
-		return header{}, fmt.Errorf("reading content: %w", err)
+		return Message{}, nil, err
 	}
 
-	lines := bytes.Split(raw, []byte("\n"))
-	headerDividerCount := 0
+	msg := Message{
+		From:       envelope.From,
+		To:         envelope.To,
+		Cc:         envelope.Cc,
+		Bcc:        envelope.Bcc,
+		ReplyTo:    envelope.ReplyTo,
+		Subject:    envelope.Subject,
+		Date:       envelope.Date,
+		MessageID:  envelope.MessageID,
+		InReplyTo:  envelope.InReplyTo,
+		References: envelope.References,
+		Tags:       doc.List(frontmatter.TagsKey),
+		Body:       bytes.NewBufferString(strings.TrimSpace(doc.Body)),
+		Headers:    envelope.Headers,
+	}
 
-	hdr := header{}
+	if values := doc.Values(frontmatter.FlagsKey); len(values) > 0 {
+		msg.Flags = flags.Parse(strings.Join(values, ","))
+	}
 
-	for _, line := range lines {
-		if headerDividerCount == 2 {
-			break
-		}
+	return msg, doc.List(frontmatter.AttachmentsKey), nil
+}
 
-		switch {
-		case bytes.HasPrefix(line, []byte("---")):
-			headerDividerCount++
-		case bytes.HasPrefix(line, []byte("From:")):
-			hdr.From = string(bytes.TrimPrefix(line, []byte("From: ")))
-		case bytes.HasPrefix(line, []byte("To:")):
-			hdr.To = string(bytes.TrimPrefix(line, []byte("To: ")))
-		case bytes.HasPrefix(line, []byte("Subject:")):
-			hdr.Subject = string(bytes.TrimPrefix(line, []byte("Subject: ")))
-		default:
-			return header{}, fmt.Errorf("invalid header line: %s", line)
-		}
-	}
+// messageToDocument turns a message into the content of a message file written in format
+func messageToDocument(msg Message, format frontmatter.Format, body string, attachmentPaths []string) frontmatter.Document {
+	doc := frontmatter.Document{Format: format, Body: body}
+
+	doc.AddEnvelope(frontmatter.Envelope{
+		From:       msg.From,
+		To:         msg.To,
+		Cc:         msg.Cc,
+		Bcc:        msg.Bcc,
+		ReplyTo:    msg.ReplyTo,
+		Subject:    msg.Subject,
+		Date:       msg.Date,
+		MessageID:  msg.MessageID,
+		InReplyTo:  msg.InReplyTo,
+		References: msg.References,
+		Headers:    msg.Headers,
+	})
 
-	return hdr, nil
+	doc.AddList(frontmatter.AttachmentsKey, listSeparator, attachmentPaths)
+	doc.AddList(frontmatter.FlagsKey, listSeparator, flags.Normalize(msg.Flags))
+	doc.AddList(frontmatter.TagsKey, listSeparator, msg.Tags)
+
+	return doc
 }
 
-func extractBody(content io.Reader) (io.Reader, error) {
-	raw, err := io.ReadAll(content)
+func DirectoryToMessages(fs *afero.Afero, targetDir string) ([]Message, error) {
+	files, err := fs.ReadDir(targetDir)
 	if err != nil {
-		return nil, fmt.Errorf("reading content: %w", err)
+		return nil, fmt.Errorf("listing: %w", err)
 	}
 
-	lines := bytes.Split(raw, []byte("\n"))
-	headerDividerCount := 0
-
-	body := bytes.Buffer{}
+	messages := make([]Message, 0)
 
-	for _, line := range lines {
-		if headerDividerCount == 2 {
-			body.Write(line)
-			body.Write([]byte("\n"))
+	for _, file := range files {
+		if file.IsDir() || IsSidecar(file.Name()) {
 			continue
 		}
 
-		switch {
-		case bytes.HasPrefix(line, []byte("---")):
-			headerDividerCount++
-		default:
-			continue
+		message, err := ReadMessage(fs, targetDir, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file.Name(), err)
 		}
+
+		messages = append(messages, message)
 	}
 
-	bodyAsBytes := bytes.TrimSpace(body.Bytes())
+	return messages, nil
+}
+
+// ReadMessage knows how to read a message file in targetDir, including the attachments listed in its header
+func ReadMessage(fs *afero.Afero, targetDir string, filename string) (Message, error) {
+	raw, err := fs.ReadFile(path.Join(targetDir, filename))
+	if err != nil {
+		return Message{}, fmt.Errorf("reading: %w", err)
+	}
+
+	doc, err := frontmatter.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, fmt.Errorf("parsing: %w", err)
+	}
+
+	msg, attachmentPaths, err := documentToMessage(doc)
+	if err != nil {
+		return Message{}, fmt.Errorf("converting: %w", err)
+	}
 
-	return bytes.NewBuffer(bodyAsBytes), nil
+	msg.Attachments, err = readAttachments(fs, targetDir, attachmentPaths)
+	if err != nil {
+		return Message{}, fmt.Errorf("reading attachments: %w", err)
+	}
+
+	return msg, nil
 }
 
-func DirectoryToMessages(fs *afero.Afero, targetDir string) ([]Message, error) {
-	files, err := fs.ReadDir(targetDir)
+// ReadMessageID knows how to read the Message-ID from the header of the message file at filePath, without the angle
+// brackets. Files that are not message files have none
+func ReadMessageID(fs *afero.Afero, filePath string) (string, error) {
+	raw, err := fs.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("listing: %w", err)
+		return "", fmt.Errorf("reading: %w", err)
 	}
 
-	messages := make([]Message, 0)
+	doc, err := frontmatter.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return "", nil
+	}
 
-	for _, file := range files {
-		filePath := path.Join(targetDir, file.Name())
+	return messageid.Parse(doc.Get(frontmatter.MessageIDKey)), nil
+}
 
-		raw, err := fs.ReadFile(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("reading: %w", err)
-		}
+// addressKeys contains the header fields holding addresses
+var addressKeys = []string{
+	frontmatter.FromKey, frontmatter.ToKey, frontmatter.CcKey, frontmatter.BccKey, frontmatter.ReplyToKey,
+}
 
-		hdr, err := extractHeader(bytes.NewReader(raw))
-		if err != nil {
-			return nil, fmt.Errorf("extracting header: %w", err)
-		}
+// ReadAddresses knows how to read the addresses in the address fields of the message file at filePath. Fields that can
+// not be parsed are skipped, and files that are not message files have none
+func ReadAddresses(fs *afero.Afero, filePath string) ([]string, error) {
+	raw, err := fs.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading: %w", err)
+	}
+
+	doc, err := frontmatter.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil
+	}
+
+	result := make([]string, 0)
 
-		body, err := extractBody(bytes.NewReader(raw))
+	for _, key := range addressKeys {
+		list, err := doc.Addresses(key)
 		if err != nil {
-			return nil, fmt.Errorf("extracting body: %w", err)
+			continue
 		}
 
-		messages = append(messages, Message{
-			To:      hdr.To,
-			From:    hdr.From,
-			Subject: hdr.Subject,
-			Body:    body,
-		})
+		result = append(result, list...)
 	}
 
-	return messages, nil
+	return result, nil
 }
 
-func WriteMessagesToDirectory(fs *afero.Afero, targetDir string, messages []Message) error {
+func WriteMessagesToDirectory(
+	fs *afero.Afero, targetDir string, namer Namer, format frontmatter.Format, messages []Message,
+) error {
 	err := fs.MkdirAll(targetDir, 0o755)
 	if err != nil {
 		return fmt.Errorf("creating directory: %w", err)
 	}
 
 	for _, message := range messages {
-		err = WriteMessageToDirectory(fs, targetDir, message)
+		_, err = WriteMessageToDirectory(fs, targetDir, namer, format, message)
 		if err != nil {
 			return fmt.Errorf("writing message: %w", err)
 		}
@@ -134,47 +186,51 @@ func WriteMessagesToDirectory(fs *afero.Afero, targetDir string, messages []Mess
 	return nil
 }
 
-func WriteMessageToDirectory(fs *afero.Afero, targetDir string, message Message) error {
-	t, err := template.New("message").Parse(messageFileTemplate)
+// WriteMessageToDirectory knows how to write a message as a file in targetDir, named by namer and with the header block
+// written in format. It returns the name of the file
+func WriteMessageToDirectory(
+	fs *afero.Afero, targetDir string, namer Namer, format frontmatter.Format, message Message,
+) (string, error) {
+	rawBody, err := io.ReadAll(message.Body)
 	if err != nil {
-		return fmt.Errorf("parsing template: %w", err)
+		return "", fmt.Errorf("buffering body: %w", err)
 	}
 
-	buf := bytes.Buffer{}
-
-	rawBody, err := io.ReadAll(message.Body)
-	if err != nil {
-		return fmt.Errorf("buffering body: %w", err)
-	}
-
-	err = t.Execute(&buf, struct {
-		To      string
-		Cc      string
-		Bcc     string
-		Subject string
-		Body    string
-	}{
-		To:      message.To,
-		Cc:      formatList(message.Cc),
-		Subject: message.Subject,
-		Body:    string(rawBody),
-	})
+	filename, err := namer.Name(fs, targetDir, message)
 	if err != nil {
-		return fmt.Errorf("executing template: %w", err)
+		return "", fmt.Errorf("naming file: %w", err)
 	}
 
-	err = fs.WriteReader(path.Join(targetDir, subjectAsFilename(message.Subject)), &buf)
+	attachmentPaths, err := WriteAttachments(fs, targetDir, filename, message.Attachments)
 	if err != nil {
-		return fmt.Errorf("writing file: %w", err)
+		return "", fmt.Errorf("writing attachments: %w", err)
 	}
 
-	return nil
-}
+	if message.HTML != "" {
+		err = fs.WriteFile(path.Join(targetDir, HTMLSidecar(filename)), []byte(message.HTML), defaultFilePermissions)
+		if err != nil {
+			return "", fmt.Errorf("writing HTML: %w", err)
+		}
+	}
 
-func subjectAsFilename(subject string) string {
-	return strings.ReplaceAll(subject, " ", "-")
-}
+	if len(message.Raw) > 0 {
+		err = fs.WriteFile(path.Join(targetDir, EMLSidecar(filename)), message.Raw, defaultFilePermissions)
+		if err != nil {
+			return "", fmt.Errorf("writing raw message: %w", err)
+		}
+	}
+
+	body := string(rawBody)
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+
+	content := frontmatter.Serialize(messageToDocument(message, format, body, attachmentPaths))
+
+	err = fs.WriteFile(path.Join(targetDir, filename), content, defaultFilePermissions)
+	if err != nil {
+		return "", fmt.Errorf("writing file: %w", err)
+	}
 
-func formatList(list []string) string {
-	return strings.Join(list, ", ")
+	return filename, nil
 }
@@ -0,0 +1,184 @@
+package fsconv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	attachmentsDirectorySuffix = ".attachments"
+	htmlSidecarSuffix          = ".html"
+	emlSidecarSuffix           = ".eml"
+	defaultAttachmentName      = "attachment"
+	maxAttachmentNameLength    = 200
+)
+
+// AttachmentsDirectory knows how to find the name of the directory containing the attachments of a message file
+func AttachmentsDirectory(messageFilename string) string {
+	return messageFilename + attachmentsDirectorySuffix
+}
+
+// HTMLSidecar knows how to find the name of the file containing the original HTML of a message file
+func HTMLSidecar(messageFilename string) string {
+	return messageFilename + htmlSidecarSuffix
+}
+
+// EMLSidecar knows how to find the name of the file containing the original RFC 5322 message of a message file
+func EMLSidecar(messageFilename string) string {
+	return messageFilename + emlSidecarSuffix
+}
+
+// IsSidecar knows if a directory entry belongs to a message file instead of being a message itself
+func IsSidecar(name string) bool {
+	for _, suffix := range []string{attachmentsDirectorySuffix, htmlSidecarSuffix, emlSidecarSuffix} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readAttachments loads the attachments listed in the header of a message file. Paths are relative to targetDir
+func readAttachments(fs *afero.Afero, targetDir string, relativePaths []string) ([]Attachment, error) {
+	if len(relativePaths) == 0 {
+		return nil, nil
+	}
+
+	result := make([]Attachment, len(relativePaths))
+
+	for index, relativePath := range relativePaths {
+		content, err := fs.ReadFile(path.Join(targetDir, relativePath))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", relativePath, err)
+		}
+
+		result[index] = Attachment{Filename: path.Base(relativePath), Content: content}
+	}
+
+	return result, nil
+}
+
+// RemoveSidecars knows how to remove the files and directories belonging to a message file
+func RemoveSidecars(fs *afero.Afero, targetDir string, messageFilename string) error {
+	err := fs.RemoveAll(path.Join(targetDir, AttachmentsDirectory(messageFilename)))
+	if err != nil {
+		return fmt.Errorf("removing attachments: %w", err)
+	}
+
+	err = fs.Remove(path.Join(targetDir, HTMLSidecar(messageFilename)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing HTML: %w", err)
+	}
+
+	err = fs.Remove(path.Join(targetDir, EMLSidecar(messageFilename)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing raw message: %w", err)
+	}
+
+	return nil
+}
+
+// WriteAttachments knows how to store attachments in the attachments directory of a message file. It returns the paths
+// of the stored attachments relative to targetDir
+func WriteAttachments(fs *afero.Afero, targetDir string, messageFilename string, attachments []Attachment) ([]string, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	attachmentsDir := AttachmentsDirectory(messageFilename)
+
+	err := fs.RemoveAll(path.Join(targetDir, attachmentsDir))
+	if err != nil {
+		return nil, fmt.Errorf("clearing attachments directory: %w", err)
+	}
+
+	err = fs.MkdirAll(path.Join(targetDir, attachmentsDir), defaultDirectoryPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("creating attachments directory: %w", err)
+	}
+
+	taken := make(map[string]bool)
+	result := make([]string, 0, len(attachments))
+
+	for _, attachment := range attachments {
+		name := deduplicate(taken, sanitizeFilename(attachment.Filename))
+		relativePath := path.Join(attachmentsDir, name)
+
+		err = fs.WriteFile(path.Join(targetDir, relativePath), attachment.Content, defaultFilePermissions)
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+
+		result = append(result, relativePath)
+	}
+
+	return result, nil
+}
+
+// sanitizeFilename turns a sender provided filename into a name that is safe to use on common filesystems and in the
+// comma separated Attachments header
+func sanitizeFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|,`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, filename)
+
+	filename = strings.Trim(filename, " .")
+
+	if filename == "" {
+		return defaultAttachmentName
+	}
+
+	if len(filename) > maxAttachmentNameLength {
+		extension := path.Ext(filename)
+		if len(extension) > maxAttachmentNameLength/2 {
+			extension = ""
+		}
+
+		filename = truncate(strings.TrimSuffix(filename, extension), maxAttachmentNameLength-len(extension)) + extension
+	}
+
+	return filename
+}
+
+// deduplicate returns name, or name with a numbered suffix in front of the extension when name is already taken
+func deduplicate(taken map[string]bool, name string) string {
+	candidate := name
+	extension := path.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+
+	for index := 1; taken[strings.ToLower(candidate)]; index++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, index, extension)
+	}
+
+	taken[strings.ToLower(candidate)] = true
+
+	return candidate
+}
+
+// truncate shortens s to at most length bytes without splitting a multi-byte character
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	for length > 0 && !utf8.RuneStart(s[length]) {
+		length--
+	}
+
+	return s[:length]
+}
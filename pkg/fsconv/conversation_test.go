@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/mail"
 	"path"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
 
+	"github.com/deifyed/fsmail/pkg/frontmatter"
 	"github.com/sebdah/goldie/v2"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +20,8 @@ import (
 func TestMessageToFile(t *testing.T) {
 	testCases := []struct {
 		name                string
+		withTemplate        string
+		withFormat          frontmatter.Format
 		withMessages        []Message
 		expectExistingFiles []string
 	}{
@@ -24,7 +29,7 @@ func TestMessageToFile(t *testing.T) {
 			name: "Should generate expected file with one message",
 			withMessages: []Message{
 				{
-					To:      "me@example.com",
+					To:      []string{"me@example.com"},
 					Subject: "This is a test subject",
 					Body:    strings.NewReader("Mock content"),
 				},
@@ -35,7 +40,7 @@ func TestMessageToFile(t *testing.T) {
 			name: "Should generate expected file with one message and multiple recipients",
 			withMessages: []Message{
 				{
-					To:      "me@example.com",
+					To:      []string{"me@example.com"},
 					Cc:      []string{"someone@example.com", "else@example.com"},
 					Subject: "This is a test subject",
 					Body:    strings.NewReader("Mock content"),
@@ -43,6 +48,166 @@ func TestMessageToFile(t *testing.T) {
 			},
 			expectExistingFiles: []string{"/work/This-is-a-test-subject"},
 		},
+		{
+			name: "Should generate expected file with every address field",
+			withMessages: []Message{
+				{
+					From:    "Me <me@example.com>",
+					To:      []string{"Jane Doe <jane@example.com>", "john@example.com"},
+					Cc:      []string{`"Doe, Jim" <jim@example.com>`},
+					Bcc:     []string{"boss@example.com"},
+					ReplyTo: []string{"Team <team@example.com>"},
+					Subject: "Meeting",
+					Body:    strings.NewReader("Mock content"),
+				},
+			},
+			expectExistingFiles: []string{"/work/Meeting"},
+		},
+		{
+			name: "Should generate expected file with attachments",
+			withMessages: []Message{
+				{
+					To:      []string{"me@example.com"},
+					Subject: "Invoice",
+					Body:    strings.NewReader("Mock content"),
+					Attachments: []Attachment{
+						{Filename: "invoice.pdf", Content: []byte("mock invoice")},
+						{Filename: "Invoice.pdf", Content: []byte("another mock invoice")},
+						{Filename: "../secret", Content: []byte("mock secret")},
+						{Content: []byte("mock nameless")},
+					},
+				},
+			},
+			expectExistingFiles: []string{
+				"/work/Invoice",
+				"/work/Invoice.attachments/invoice.pdf",
+				"/work/Invoice.attachments/Invoice-1.pdf",
+				"/work/Invoice.attachments/_secret",
+				"/work/Invoice.attachments/attachment",
+			},
+		},
+		{
+			name: "Should generate expected file with an HTML sidecar",
+			withMessages: []Message{
+				{
+					To:      []string{"me@example.com"},
+					Subject: "Newsletter",
+					Body:    strings.NewReader("# Mock content"),
+					HTML:    "<h1>Mock content</h1>",
+				},
+			},
+			expectExistingFiles: []string{"/work/Newsletter", "/work/Newsletter.html"},
+		},
+		{
+			name: "Should generate expected file with a raw message sidecar",
+			withMessages: []Message{
+				{
+					To:      []string{"me@example.com"},
+					Subject: "Signed",
+					Body:    strings.NewReader("Mock content"),
+					Raw:     []byte("To: me@example.com\r\nSubject: Signed\r\n\r\nMock content\r\n"),
+				},
+			},
+			expectExistingFiles: []string{"/work/Signed", "/work/Signed.eml"},
+		},
+		{
+			name: "Should generate distinct files for messages with the same subject",
+			withMessages: []Message{
+				{
+					To:        []string{"me@example.com"},
+					Subject:   "Re: Hello",
+					MessageID: "first@example.com",
+					Body:      strings.NewReader("First mock content"),
+				},
+				{
+					To:        []string{"me@example.com"},
+					Subject:   "Re: Hello",
+					MessageID: "second@example.com",
+					Body:      strings.NewReader("Second mock content"),
+				},
+				{
+					To:      []string{"me@example.com"},
+					Subject: "Re: Hello",
+					Body:    strings.NewReader("Third mock content"),
+				},
+			},
+			expectExistingFiles: []string{"/work/Re_-Hello", "/work/Re_-Hello_27b67768", "/work/Re_-Hello-1"},
+		},
+		{
+			name: "Should generate safe filenames for unsafe subjects",
+			withMessages: []Message{
+				{
+					To:      []string{"me@example.com"},
+					Subject: "../../etc/passwd",
+					Body:    strings.NewReader("Mock content"),
+				},
+				{
+					To:   []string{"me@example.com"},
+					Body: strings.NewReader("Mock content"),
+				},
+				{
+					To:      []string{"me@example.com"},
+					Subject: "index.html",
+					Body:    strings.NewReader("Mock content"),
+				},
+			},
+			expectExistingFiles: []string{"/work/_.._etc_passwd", "/work/no-subject", "/work/index_html"},
+		},
+		{
+			name:         "Should generate expected file with a custom filename template",
+			withTemplate: `{{ .Date | date "2006-01-02" }}_{{ .From | address }}_{{ .Subject | slug }}`,
+			withMessages: []Message{
+				{
+					From:    "Jane Doe <jane@example.com>",
+					To:      []string{"me@example.com"},
+					Subject: "Quarterly Report: Q3/2022!",
+					Date:    time.Date(2022, time.October, 3, 12, 0, 0, 0, time.UTC),
+					Body:    strings.NewReader("Mock content"),
+				},
+			},
+			expectExistingFiles: []string{"/work/2022-10-03_jane@example.com_quarterly-report-q3-2022"},
+		},
+		{
+			name: "Should generate expected file with date and threading headers",
+			withMessages: []Message{
+				{
+					From:       "you@example.com",
+					To:         []string{"me@example.com"},
+					Subject:    "Re: Hello",
+					Date:       time.Date(2022, time.October, 3, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+					MessageID:  "third@example.com",
+					InReplyTo:  "second@example.com",
+					References: []string{"first@example.com", "second@example.com"},
+					Body:       strings.NewReader("Mock content"),
+				},
+			},
+			expectExistingFiles: []string{"/work/Re_-Hello"},
+		},
+		{
+			name:       "Should generate expected file with YAML front matter",
+			withFormat: frontmatter.FormatYAML,
+			withMessages: []Message{
+				{
+					From:       "you@example.com",
+					To:         []string{"me@example.com", "Jane Doe <jane@example.com>"},
+					Subject:    "Re: Hello",
+					Date:       time.Date(2022, time.October, 3, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+					MessageID:  "third@example.com",
+					InReplyTo:  "second@example.com",
+					References: []string{"first@example.com", "second@example.com"},
+					Flags:      []string{"seen"},
+					Tags:       []string{"work"},
+					Body:       strings.NewReader("Mock content"),
+					Attachments: []Attachment{
+						{Filename: "report.pdf", Content: []byte("mock report")},
+					},
+					Headers: []frontmatter.Header{
+						{Key: "X-Mailer", Value: "mock mailer"},
+					},
+				},
+			},
+			expectExistingFiles: []string{"/work/Re_-Hello"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -54,8 +219,21 @@ func TestMessageToFile(t *testing.T) {
 			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
 			workDir := "/work"
 
+			filenameTemplate := tc.withTemplate
+			if filenameTemplate == "" {
+				filenameTemplate = DefaultFilenameTemplate
+			}
+
+			format := tc.withFormat
+			if format == "" {
+				format = frontmatter.FormatHeaders
+			}
+
+			namer, err := NewNamer(filenameTemplate)
+			assert.NoError(t, err)
+
 			for _, message := range tc.withMessages {
-				err := WriteMessageToDirectory(fs, workDir, message)
+				_, err = WriteMessageToDirectory(fs, workDir, namer, format, message)
 				assert.NoError(t, err)
 			}
 
@@ -76,6 +254,128 @@ func TestMessageToFile(t *testing.T) {
 	}
 }
 
+func TestMessageRoundTrip(t *testing.T) {
+	for _, format := range []frontmatter.Format{frontmatter.FormatHeaders, frontmatter.FormatYAML} {
+		format := format
+
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			namer, err := NewNamer(DefaultFilenameTemplate)
+			assert.NoError(t, err)
+
+			original := Message{
+				From:       "Jane Doe <jane@example.com>",
+				To:         []string{"me@example.com", `"Doe, Jim" <jim@example.com>`},
+				Cc:         []string{"team@example.com"},
+				Subject:    "Re: Quarterly report",
+				Date:       mustParseDate(t, "Mon, 03 Oct 2022 12:00:00 +0200"),
+				MessageID:  "second@example.com",
+				InReplyTo:  "first@example.com",
+				References: []string{"first@example.com"},
+				Flags:      []string{"seen", "flagged"},
+				Tags:       []string{"finance", "q3"},
+				Body:       bytes.NewBufferString("See attached\n\n---\nJane"),
+				Attachments: []Attachment{
+					{Filename: "report.pdf", Content: []byte("mock report")},
+				},
+				Headers: []frontmatter.Header{
+					{Key: "X-Mailer", Value: "mock mailer"},
+				},
+			}
+
+			filename, err := WriteMessageToDirectory(fs, "/work", namer, format, original)
+			assert.NoError(t, err)
+
+			original.Body = bytes.NewBufferString("See attached\n\n---\nJane")
+
+			result, err := ReadMessage(fs, "/work", filename)
+			assert.NoError(t, err)
+
+			assert.Equal(t, original, result)
+		})
+	}
+}
+
+func TestWriteSameMessageTwice(t *testing.T) {
+	for _, format := range []frontmatter.Format{frontmatter.FormatHeaders, frontmatter.FormatYAML} {
+		format := format
+
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			namer, err := NewNamer(DefaultFilenameTemplate)
+			assert.NoError(t, err)
+
+			filenames := make([]string, 0, 2)
+
+			for _, body := range []string{"First copy", "Second copy"} {
+				filename, err := WriteMessageToDirectory(fs, "/work", namer, format, Message{
+					Subject:   "Re: Hello",
+					MessageID: "first@example.com",
+					Body:      strings.NewReader(body),
+				})
+				assert.NoError(t, err)
+
+				filenames = append(filenames, filename)
+			}
+
+			assert.Equal(t, []string{"Re_-Hello", "Re_-Hello"}, filenames)
+
+			files, err := fs.ReadDir("/work")
+			assert.NoError(t, err)
+			assert.Len(t, files, 1)
+
+			result, err := ReadMessage(fs, "/work", "Re_-Hello")
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(result.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "Second copy", string(body))
+		})
+	}
+}
+
+func TestNameCollisions(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	namer, err := NewNamer(DefaultFilenameTemplate)
+	assert.NoError(t, err)
+
+	suffixed := "Hello_" + messageIDSuffix("hello@example.com")
+
+	// The plain name belongs to another message, and the suffixed name to a file that is no message at all
+	err = fs.WriteFile("/work/Hello", []byte("---\nMessage-ID: <other@example.com>\n---\n\nOther\n"), 0o600)
+	assert.NoError(t, err)
+
+	err = fs.WriteFile(path.Join("/work", suffixed), []byte("Notes"), 0o600)
+	assert.NoError(t, err)
+
+	write := func(messageID string) string {
+		filename, err := WriteMessageToDirectory(fs, "/work", namer, frontmatter.FormatHeaders, Message{
+			Subject:   "Hello",
+			MessageID: messageID,
+			Body:      strings.NewReader("Hello"),
+		})
+		assert.NoError(t, err)
+
+		return filename
+	}
+
+	assert.Equal(t, suffixed+"-1", write("hello@example.com"))
+	assert.Equal(t, suffixed+"-1", write("hello@example.com"), "writing the message again replaces it")
+	assert.Equal(t, "Hello-1", write(""))
+	assert.Equal(t, "Hello-2", write(""))
+
+	notes, err := fs.ReadFile(path.Join("/work", suffixed))
+	assert.NoError(t, err)
+	assert.Equal(t, "Notes", string(notes))
+}
+
 type testFile struct {
 	filepath string
 	content  io.Reader
@@ -127,12 +427,43 @@ func TestDirectoryToMessages(t *testing.T) {
 			expectMessages: []Message{
 				{
 					From:    "me@example.com",
-					To:      "you@example.com",
+					To:      []string{"you@example.com"},
 					Subject: "mock subject",
 					Body:    bytes.NewBuffer([]byte("mock body")),
 				},
 			},
 		},
+		{
+			name: "Should extract date and threading headers",
+			withFiles: []testFile{
+				{
+					filepath: "/Re-Hello",
+					content: strings.NewReader(`---
+To: you@example.com
+From: me@example.com
+Subject: Re: Hello
+Date: Mon, 03 Oct 2022 12:00:00 +0200
+Message-ID: <second@example.com>
+In-Reply-To: <first@example.com>
+References: <first@example.com>
+---
+
+mock body`),
+				},
+			},
+			expectMessages: []Message{
+				{
+					From:       "me@example.com",
+					To:         []string{"you@example.com"},
+					Subject:    "Re: Hello",
+					Date:       mustParseDate(t, "Mon, 03 Oct 2022 12:00:00 +0200"),
+					MessageID:  "second@example.com",
+					InReplyTo:  "first@example.com",
+					References: []string{"first@example.com"},
+					Body:       bytes.NewBuffer([]byte("mock body")),
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -176,6 +507,15 @@ func TestDirectoryToMessages(t *testing.T) {
 	}
 }
 
+func mustParseDate(t *testing.T, raw string) time.Time {
+	t.Helper()
+
+	date, err := mail.ParseDate(raw)
+	assert.NoError(t, err)
+
+	return date
+}
+
 func messagesAsMap(messages []Message) map[string]Message {
 	m := make(map[string]Message)
 
@@ -185,3 +525,63 @@ func messagesAsMap(messages []Message) map[string]Message {
 
 	return m
 }
+
+func TestUpdateFlags(t *testing.T) {
+	testCases := []struct {
+		name          string
+		withContent   string
+		withFlags     []string
+		expectContent string
+	}{
+		{
+			name:          "Should add flags to a file without flags",
+			withContent:   "---\nTo: me@example.com\nSubject: mock subject\n---\n\nmock body\n",
+			withFlags:     []string{"flagged", "seen"},
+			expectContent: "---\nTo: me@example.com\nSubject: mock subject\nFlags: seen, flagged\n---\n\nmock body\n",
+		},
+		{
+			name:          "Should replace existing flags",
+			withContent:   "---\nTo: me@example.com\nFlags: seen\nSubject: mock subject\n---\n\nFlags: body\n",
+			withFlags:     []string{"answered"},
+			expectContent: "---\nTo: me@example.com\nSubject: mock subject\nFlags: answered\n---\n\nFlags: body\n",
+		},
+		{
+			name:          "Should remove flags",
+			withContent:   "---\nTo: me@example.com\nSubject: mock subject\nFlags: seen\n---\n\nmock body\n",
+			withFlags:     []string{},
+			expectContent: "---\nTo: me@example.com\nSubject: mock subject\n---\n\nmock body\n",
+		},
+		{
+			name:          "Should keep YAML front matter",
+			withContent:   "---\nto: me@example.com\nflags: seen\naliases: [mock]\n---\n\nmock body\n",
+			withFlags:     []string{"seen", "answered"},
+			expectContent: "---\nto: me@example.com\naliases:\n  - mock\nflags:\n  - seen\n  - answered\n---\n\nmock body\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			err := fs.WriteFile("/work/message", []byte(tc.withContent), 0o600)
+			assert.NoError(t, err)
+
+			err = UpdateFlags(fs, "/work/message", tc.withFlags)
+			assert.NoError(t, err)
+
+			content, err := fs.ReadFile("/work/message")
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectContent, string(content))
+
+			readFlags, err := ReadFlags(fs, "/work/message")
+			assert.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.withFlags, readFlags)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package fsconv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/deifyed/fsmail/pkg/addresses"
+	"github.com/spf13/afero"
+)
+
+// DefaultFilenameTemplate names message files after their subject
+const DefaultFilenameTemplate = "{{ .Subject }}"
+
+const (
+	defaultMessageName    = "no-subject"
+	maxMessageNameLength  = 200
+	messageIDSuffixLength = 8
+)
+
+var filenameFuncs = template.FuncMap{
+	"slug":    slug,
+	"date":    formatDate,
+	"address": bareAddress,
+	"name":    displayName,
+}
+
+// Namer knows how to pick the filename of a message file
+type Namer struct {
+	template *template.Template
+}
+
+// NewNamer creates a Namer from a text/template executed with the Message to name. Besides the builtin functions, the
+// template can use slug, date, address and name
+func NewNamer(filenameTemplate string) (Namer, error) {
+	t, err := template.New("filename").Funcs(filenameFuncs).Parse(filenameTemplate)
+	if err != nil {
+		return Namer{}, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return Namer{template: t}, nil
+}
+
+// Name renders the template for message into a filesystem safe name. When the name is already taken in targetDir by
+// a file with the same Message-ID, the name is reused, so writing a message again replaces the earlier copy. When it is
+// taken by another message, a suffix derived from the Message-ID is appended, followed by a counter when that name is
+// taken as well. Names taken by other files are never returned
+func (n Namer) Name(fs *afero.Afero, targetDir string, message Message) (string, error) {
+	buf := bytes.Buffer{}
+
+	err := n.template.Execute(&buf, message)
+	if err != nil {
+		return "", fmt.Errorf("executing template: %w", err)
+	}
+
+	name := sanitizeMessageName(buf.String())
+
+	candidates := []string{name}
+
+	if message.MessageID != "" {
+		name = fmt.Sprintf("%s_%s", name, messageIDSuffix(message.MessageID))
+		candidates = append(candidates, name)
+	}
+
+	for index := 1; ; index++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%d", name, index))
+
+		for _, candidate := range candidates {
+			free, err := isFree(fs, targetDir, candidate, message.MessageID)
+			if err != nil {
+				return "", err
+			}
+
+			if free {
+				return candidate, nil
+			}
+		}
+
+		candidates = candidates[:0]
+	}
+}
+
+// isFree knows if a message with messageID can be written to name in targetDir, which is when there is no such file
+// or the file holds the same message
+func isFree(fs *afero.Afero, targetDir string, name string, messageID string) (bool, error) {
+	taken, err := fs.Exists(path.Join(targetDir, name))
+	if err != nil {
+		return false, fmt.Errorf("checking existence of %s: %w", name, err)
+	}
+
+	if !taken {
+		return true, nil
+	}
+
+	if messageID == "" {
+		return false, nil
+	}
+
+	same, err := hasMessageID(fs, path.Join(targetDir, name), messageID)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	return same, nil
+}
+
+// hasMessageID knows if the message file at filePath carries messageID
+func hasMessageID(fs *afero.Afero, filePath string, messageID string) (bool, error) {
+	existing, err := ReadMessageID(fs, filePath)
+	if err != nil {
+		return false, err
+	}
+
+	return existing == messageID, nil
+}
+
+// sanitizeMessageName turns a rendered filename into a name that is safe to use on common filesystems, is not hidden
+// and can not be mistaken for a sidecar
+func sanitizeMessageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case unicode.IsSpace(r):
+			return '-'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+
+	name = truncate(strings.Trim(name, ".-"), maxMessageNameLength)
+
+	if name == "" {
+		return defaultMessageName
+	}
+
+	if IsSidecar(name) {
+		extension := path.Ext(name)
+
+		name = strings.TrimSuffix(name, extension) + "_" + strings.TrimPrefix(extension, ".")
+	}
+
+	return name
+}
+
+func messageIDSuffix(messageID string) string {
+	sum := sha256.Sum256([]byte(messageID))
+
+	return hex.EncodeToString(sum[:])[:messageIDSuffixLength]
+}
+
+// slug turns text into lower case words separated by dashes
+func slug(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, "-")
+}
+
+func formatDate(layout string, date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.Format(layout)
+}
+
+// bareAddress returns the address part of an address with a display name
+func bareAddress(raw string) string {
+	_, address, err := addresses.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	return address
+}
+
+// displayName returns the display name of an address, or the address when it has no display name
+func displayName(raw string) string {
+	name, address, err := addresses.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	if name == "" {
+		return address
+	}
+
+	return name
+}
@@ -0,0 +1,53 @@
+package fsconv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+	"github.com/spf13/afero"
+)
+
+// ReadFlags knows how to read the flags from the header of a message file
+func ReadFlags(fs *afero.Afero, filePath string) ([]string, error) {
+	doc, err := readDocument(fs, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return flags.Normalize(flags.Parse(strings.Join(doc.Values(frontmatter.FlagsKey), ","))), nil
+}
+
+// UpdateFlags knows how to replace the flags in the header of a message file, leaving the rest of the file untouched
+func UpdateFlags(fs *afero.Afero, filePath string, flagList []string) error {
+	doc, err := readDocument(fs, filePath)
+	if err != nil {
+		return err
+	}
+
+	doc.Del(frontmatter.FlagsKey)
+	doc.AddList(frontmatter.FlagsKey, listSeparator, flags.Normalize(flagList))
+
+	err = fs.WriteFile(filePath, frontmatter.Serialize(doc), defaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+
+	return nil
+}
+
+func readDocument(fs *afero.Afero, filePath string) (frontmatter.Document, error) {
+	raw, err := fs.ReadFile(filePath)
+	if err != nil {
+		return frontmatter.Document{}, fmt.Errorf("reading: %w", err)
+	}
+
+	doc, err := frontmatter.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return frontmatter.Document{}, fmt.Errorf("parsing: %w", err)
+	}
+
+	return doc, nil
+}
@@ -1,17 +1,44 @@
 package fsconv
 
 import (
-	_ "embed"
 	"io"
-)
+	"time"
 
-//go:embed file-template.md
-var messageFileTemplate string
+	"github.com/deifyed/fsmail/pkg/frontmatter"
+)
 
 type Message struct {
-	To      string
+	To      []string
 	From    string
 	Cc      []string
+	Bcc     []string
+	ReplyTo []string
 	Subject string
-	Body    io.Reader
+	Date    time.Time
+	// MessageID, InReplyTo and References contain message IDs without angle brackets
+	MessageID  string
+	InReplyTo  string
+	References []string
+	Flags      []string
+	// Tags contains labels added to the message file by the user. They are local and never synchronized
+	Tags []string
+	Body io.Reader
+	// HTML contains the original HTML of the message. Stored in a sidecar file next to the message file when not empty
+	HTML string
+	// Raw contains the original RFC 5322 message. Stored in a sidecar file next to the message file when not empty
+	Raw         []byte
+	Attachments []Attachment
+	// Headers contains the fields of the header block fsconv does not know about, in order
+	Headers []frontmatter.Header
+}
+
+// Attachment describes a file attached to a message
+type Attachment struct {
+	Filename string
+	Content  []byte
 }
+
+const (
+	defaultFilePermissions      = 0o600
+	defaultDirectoryPermissions = 0o700
+)
@@ -61,7 +61,7 @@ func (c Client) Delete(name string) error {
 
 	err = ring.Remove(name)
 	if err != nil {
-		return fmt.Errorf("deleting: %w", err)
+		return handleError(err, fmt.Errorf("deleting: %w", err))
 	}
 
 	return nil
@@ -2,6 +2,10 @@ package keyring
 
 import (
 	"errors"
+	"fmt"
+
+	"github.com/99designs/keyring"
+	"github.com/deifyed/fsmail/pkg/credentials"
 )
 
 const (
@@ -10,9 +14,13 @@ const (
 )
 
 func handleError(err error, defaultError error) error {
+	if errors.Is(err, keyring.ErrKeyNotFound) {
+		return fmt.Errorf("%s: %w", err.Error(), credentials.ErrNotFound)
+	}
+
 	switch err.Error() {
 	case secretServiceItemNotFound:
-		return errors.New(err.Error())
+		return fmt.Errorf("%s: %w", err.Error(), credentials.ErrNotFound)
 	case secretServiceUserAborted:
 		return errors.New(err.Error())
 	default:
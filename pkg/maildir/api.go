@@ -0,0 +1,224 @@
+package maildir
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/spf13/afero"
+)
+
+// deliveries counts the messages delivered by this process, making unique names unique within the same microsecond
+var deliveries uint64
+
+// Deliver knows how to store a message in the Maildir at dir, creating the Maildir when missing. The message is written
+// into tmp first and then moved into place. Messages without flags end up in new, others in cur. It returns the unique
+// name identifying the message, which stays the same when its flags change
+func Deliver(fs *afero.Afero, dir string, raw []byte, flagList []string) (string, error) {
+	for _, name := range []string{curDirectoryName, newDirectoryName, tmpDirectoryName} {
+		err := fs.MkdirAll(path.Join(dir, name), defaultDirectoryPermissions)
+		if err != nil {
+			return "", fmt.Errorf("creating %s: %w", name, err)
+		}
+	}
+
+	key, err := uniqueName(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("generating unique name: %w", err)
+	}
+
+	tmpPath := path.Join(dir, tmpDirectoryName, key)
+
+	err = fs.WriteFile(tmpPath, raw, defaultFilePermissions)
+	if err != nil {
+		return "", fmt.Errorf("writing: %w", err)
+	}
+
+	target := path.Join(newDirectoryName, key)
+	if len(flagList) > 0 {
+		target = path.Join(curDirectoryName, withInfo(key, flagList, ""))
+	}
+
+	err = fs.Rename(tmpPath, path.Join(dir, target))
+	if err != nil {
+		return "", fmt.Errorf("moving into place: %w", err)
+	}
+
+	return key, nil
+}
+
+// Find knows how to locate the message identified by key in the Maildir at dir. It returns the path of the message file
+// relative to dir, or an empty string when the message is gone
+func Find(fs *afero.Afero, dir string, key string) (string, error) {
+	for _, name := range []string{curDirectoryName, newDirectoryName} {
+		entries, err := fs.ReadDir(path.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", fmt.Errorf("listing %s: %w", name, err)
+		}
+
+		for _, entry := range entries {
+			if entry.Name() == key || strings.HasPrefix(entry.Name(), key+infoSeparator) {
+				return path.Join(name, entry.Name()), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Keys knows how to list the unique names of the messages in the Maildir at dir, in delivery order
+func Keys(fs *afero.Afero, dir string) ([]string, error) {
+	keys := make([]string, 0)
+
+	for _, name := range []string{curDirectoryName, newDirectoryName} {
+		entries, err := fs.ReadDir(path.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("listing %s: %w", name, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			key, _ := splitInfo(entry.Name())
+			keys = append(keys, key)
+		}
+	}
+
+	// Unique names start with the time of delivery
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Read knows how to read the message identified by key
+func Read(fs *afero.Afero, dir string, key string) ([]byte, error) {
+	relativePath, err := find(fs, dir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := fs.ReadFile(path.Join(dir, relativePath))
+	if err != nil {
+		return nil, fmt.Errorf("reading: %w", err)
+	}
+
+	return raw, nil
+}
+
+// ReadFlags knows how to read the flags of the message identified by key from the name of its file
+func ReadFlags(fs *afero.Afero, dir string, key string) ([]string, error) {
+	relativePath, err := find(fs, dir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, info := splitInfo(path.Base(relativePath))
+	result := make([]string, 0)
+
+	for flag, letter := range letters {
+		if strings.ContainsRune(info, letter) {
+			result = append(result, flag)
+		}
+	}
+
+	return flags.Normalize(result), nil
+}
+
+// SetFlags knows how to change the flags of the message identified by key. The message file is moved into cur, as the
+// message has been seen by a mail client. Flags that are not synchronized, such as T for trashed, are kept
+func SetFlags(fs *afero.Afero, dir string, key string, flagList []string) error {
+	relativePath, err := find(fs, dir, key)
+	if err != nil {
+		return err
+	}
+
+	_, info := splitInfo(path.Base(relativePath))
+
+	unsynchronized := strings.Map(func(r rune) rune {
+		for _, letter := range letters {
+			if r == letter {
+				return -1
+			}
+		}
+
+		return r
+	}, info)
+
+	target := path.Join(curDirectoryName, withInfo(key, flagList, unsynchronized))
+	if target == relativePath {
+		return nil
+	}
+
+	err = fs.Rename(path.Join(dir, relativePath), path.Join(dir, target))
+	if err != nil {
+		return fmt.Errorf("renaming: %w", err)
+	}
+
+	return nil
+}
+
+func find(fs *afero.Afero, dir string, key string) (string, error) {
+	relativePath, err := Find(fs, dir, key)
+	if err != nil {
+		return "", err
+	}
+
+	if relativePath == "" {
+		return "", fmt.Errorf("message %s: %w", key, errNotFound)
+	}
+
+	return relativePath, nil
+}
+
+// withInfo returns the name of a message file in cur. extra contains flag letters to keep besides the synchronized flags
+func withInfo(key string, flagList []string, extra string) string {
+	flagLetters := []rune(extra)
+
+	for _, flag := range flags.Normalize(flagList) {
+		flagLetters = append(flagLetters, letters[flag])
+	}
+
+	// Flag letters are written in ASCII order, as required by the Maildir specification
+	sort.Slice(flagLetters, func(i, j int) bool { return flagLetters[i] < flagLetters[j] })
+
+	return key + infoSeparator + flagsInfoPrefix + string(flagLetters)
+}
+
+// splitInfo splits the name of a message file into its unique name and the flag letters of its info
+func splitInfo(name string) (string, string) {
+	key, info, found := strings.Cut(name, infoSeparator)
+	if !found {
+		return name, ""
+	}
+
+	return key, strings.TrimPrefix(info, flagsInfoPrefix)
+}
+
+// uniqueName generates a name following the conventions of the Maildir specification, e.g. 1665000000.M1P2Q3.host
+func uniqueName(now time.Time) (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("acquiring hostname: %w", err)
+	}
+
+	hostname = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(hostname)
+
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s",
+		now.Unix(), now.Nanosecond()/int(time.Microsecond), os.Getpid(), atomic.AddUint64(&deliveries, 1), hostname,
+	), nil
+}
@@ -0,0 +1,148 @@
+package maildir
+
+import (
+	"path"
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliver(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withFlags    []string
+		expectPrefix string
+		expectSuffix string
+	}{
+		{
+			name:         "Should deliver messages without flags into new",
+			withFlags:    []string{},
+			expectPrefix: "new/",
+		},
+		{
+			name:         "Should deliver messages with flags into cur",
+			withFlags:    []string{flags.Seen, flags.Flagged},
+			expectPrefix: "cur/",
+			expectSuffix: ":2,FS",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			key, err := Deliver(fs, "/mail/INBOX", []byte("Subject: mock\r\n\r\nmock body\r\n"), tc.withFlags)
+			assert.NoError(t, err)
+
+			relativePath, err := Find(fs, "/mail/INBOX", key)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectPrefix+key+tc.expectSuffix, relativePath)
+
+			content, err := fs.ReadFile(path.Join("/mail/INBOX", relativePath))
+			assert.NoError(t, err)
+			assert.Equal(t, "Subject: mock\r\n\r\nmock body\r\n", string(content))
+
+			tmp, err := fs.ReadDir("/mail/INBOX/tmp")
+			assert.NoError(t, err)
+			assert.Empty(t, tmp)
+
+			readFlags, err := ReadFlags(fs, "/mail/INBOX", key)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tc.withFlags, readFlags)
+		})
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withPath    string
+		withFlags   []string
+		expectPath  string
+		expectFlags []string
+	}{
+		{
+			name:        "Should move new messages into cur",
+			withPath:    "new/mock",
+			withFlags:   []string{flags.Seen},
+			expectPath:  "cur/mock:2,S",
+			expectFlags: []string{flags.Seen},
+		},
+		{
+			name:        "Should keep flags that are not synchronized",
+			withPath:    "cur/mock:2,ST",
+			withFlags:   []string{flags.Flagged, flags.Answered},
+			expectPath:  "cur/mock:2,FRT",
+			expectFlags: []string{flags.Flagged, flags.Answered},
+		},
+		{
+			name:        "Should remove every synchronized flag",
+			withPath:    "cur/mock:2,FS",
+			withFlags:   []string{},
+			expectPath:  "cur/mock:2,",
+			expectFlags: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+			err := fs.WriteFile(path.Join("/mail", tc.withPath), []byte("mock"), 0o600)
+			assert.NoError(t, err)
+
+			err = SetFlags(fs, "/mail", "mock", tc.withFlags)
+			assert.NoError(t, err)
+
+			relativePath, err := Find(fs, "/mail", "mock")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectPath, relativePath)
+
+			readFlags, err := ReadFlags(fs, "/mail", "mock")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectFlags, readFlags)
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	err := fs.WriteFile("/mail/cur/mock-10:2,S", []byte("mock"), 0o600)
+	assert.NoError(t, err)
+
+	relativePath, err := Find(fs, "/mail", "mock-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "", relativePath)
+
+	_, err = ReadFlags(fs, "/mail", "mock-1")
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestKeys(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	for _, name := range []string{"/mail/cur/mock-2:2,S", "/mail/new/mock-1", "/mail/tmp/mock-3", "/mail/cur/.hidden"} {
+		err := fs.WriteFile(name, []byte(name), 0o600)
+		assert.NoError(t, err)
+	}
+
+	keys, err := Keys(fs, "/mail")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mock-1", "mock-2"}, keys)
+
+	raw, err := Read(fs, "/mail", "mock-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "/mail/cur/mock-2:2,S", string(raw))
+}
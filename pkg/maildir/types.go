@@ -0,0 +1,30 @@
+package maildir
+
+import (
+	"errors"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+)
+
+const (
+	curDirectoryName = "cur"
+	newDirectoryName = "new"
+	tmpDirectoryName = "tmp"
+
+	// infoSeparator separates the unique name of a message file from its info, e.g. 1665000000.M1P2Q3.host:2,S
+	infoSeparator = ":"
+	// flagsInfoPrefix starts the info of a message file carrying flags
+	flagsInfoPrefix = "2,"
+
+	defaultFilePermissions      = 0o600
+	defaultDirectoryPermissions = 0o700
+)
+
+// letters maps the synchronized flags to their Maildir flag letters
+var letters = map[string]rune{
+	flags.Seen:     'S',
+	flags.Flagged:  'F',
+	flags.Answered: 'R',
+}
+
+var errNotFound = errors.New("not found")
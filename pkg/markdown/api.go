@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var converter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+var htmlConverter = md.NewConverter("", true, &md.Options{
+	HeadingStyle:     "atx",
+	BulletListMarker: "-",
+}).Use(plugin.GitHubFlavored())
+
+// ToHTML knows how to render Markdown into HTML
+func ToHTML(source string) (string, error) {
+	buf := bytes.Buffer{}
+
+	err := converter.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", fmt.Errorf("converting: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// FromHTML knows how to turn HTML into readable Markdown, preserving links, lists and headings
+func FromHTML(source string) (string, error) {
+	result, err := htmlConverter.ConvertString(source)
+	if err != nil {
+		return "", fmt.Errorf("converting: %w", err)
+	}
+
+	return result, nil
+}
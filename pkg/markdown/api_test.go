@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToHTML(t *testing.T) {
+	testCases := []struct {
+		name       string
+		withSource string
+	}{
+		{
+			name:       "Should render paragraphs with hard line breaks",
+			withSource: "Hi mum,\nJust wanted to say hi.\n\nLove, son",
+		},
+		{
+			name:       "Should render lists, links and emphasis",
+			withSource: "# Agenda\n\n- *coffee*\n- [the plan](https://example.com)\n- **cake**\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := ToHTML(tc.withSource)
+			assert.NoError(t, err)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), []byte(result))
+		})
+	}
+}
+
+func TestFromHTML(t *testing.T) {
+	testCases := []struct {
+		name       string
+		withSource string
+	}{
+		{
+			name:       "Should convert paragraphs and line breaks",
+			withSource: "<html><body><p>Hi mum,<br>Just wanted to say hi.</p><p>Love, son</p></body></html>",
+		},
+		{
+			name: "Should preserve headings, lists and links",
+			withSource: `<h1>Agenda</h1>
+<ul><li><em>coffee</em></li><li><a href="https://example.com">the plan</a></li><li><strong>cake</strong></li></ul>
+<ol><li>first</li><li>second</li></ol>`,
+		},
+		{
+			name:       "Should drop styles and scripts",
+			withSource: `<html><head><style>p { color: red; }</style><script>alert("hi")</script></head><body><p>Invoice attached</p></body></html>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := FromHTML(tc.withSource)
+			assert.NoError(t, err)
+
+			g := goldie.New(t)
+
+			g.Assert(t, t.Name(), []byte(result))
+		})
+	}
+}
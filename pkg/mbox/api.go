@@ -0,0 +1,282 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+)
+
+var (
+	// escapedSeparator matches lines that would be mistaken for a From line, including ones already escaped with >
+	escapedSeparator = regexp.MustCompile(`^>*From `)
+	// escapedLine matches lines escaped by the writer of the mbox file
+	escapedLine = regexp.MustCompile(`^>+From `)
+	// separator matches From lines as written by mail programs, with a sender followed by a date containing a time
+	separator = regexp.MustCompile(`^From \S+ .*\d{1,2}:\d{2}`)
+)
+
+// NewReader creates a Reader reading the mbox file in r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message in the mbox file, or io.EOF when there are none left. A From line only starts a new
+// message after a blank line, and only when it has a sender and a date. Lines escaped as >From are unescaped
+func (r *Reader) Next() (Message, error) {
+	if r.separator == nil {
+		err := r.skipToSeparator()
+		if err != nil {
+			return Message{}, err
+		}
+	}
+
+	msg := Message{}
+	msg.Sender, msg.Date = parseSeparator(r.separator)
+	r.separator = nil
+
+	buf := bytes.Buffer{}
+	previousBlank := false
+
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return Message{}, fmt.Errorf("reading: %w", err)
+		}
+
+		if previousBlank && separator.Match(line) {
+			r.separator = line
+
+			break
+		}
+
+		previousBlank = isBlank(line)
+
+		if escapedLine.Match(line) {
+			line = line[1:]
+		}
+
+		buf.Write(line)
+	}
+
+	// The blank line in front of the next From line separates the messages and is not part of the message
+	msg.Raw = trimSeparatorLine(buf.Bytes())
+	msg.Flags = readFlags(msg.Raw)
+
+	return msg, nil
+}
+
+func (r *Reader) skipToSeparator() error {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte(separatorPrefix)) {
+			r.separator = line
+
+			return nil
+		}
+
+		if err == io.EOF {
+			return io.EOF
+		} else if err != nil {
+			return fmt.Errorf("reading: %w", err)
+		}
+	}
+}
+
+// NewWriter creates a Writer appending to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write appends a message to the mbox file. Lines that could be mistaken for a From line are escaped with >, and the
+// Status and X-Status headers are replaced by ones describing the flags of the message
+func (w *Writer) Write(msg Message) error {
+	header, body := splitHeader(msg.Raw)
+	parsedHeader := parseHeader(header)
+
+	sender := msg.Sender
+	if sender == "" {
+		sender = headerSender(parsedHeader)
+	}
+
+	date := msg.Date
+	if date.IsZero() {
+		date = headerDate(parsedHeader)
+	}
+
+	buf := bytes.Buffer{}
+
+	buf.WriteString(fmt.Sprintf("%s%s %s\n", separatorPrefix, sender, date.UTC().Format(dateLayout)))
+	buf.Write(withStatus(header, msg.Flags))
+
+	for _, line := range bytes.SplitAfter(body, []byte("\n")) {
+		if escapedSeparator.Match(line) {
+			buf.WriteByte('>')
+		}
+
+		buf.Write(line)
+	}
+
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteByte('\n')
+
+	_, err := w.w.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+
+	return nil
+}
+
+// parseSeparator reads the sender and date of a From line. Both are optional, as From lines vary between programs
+func parseSeparator(line []byte) (string, time.Time) {
+	fields := strings.Fields(strings.TrimPrefix(string(line), separatorPrefix))
+	if len(fields) == 0 {
+		return "", time.Time{}
+	}
+
+	rawDate := strings.Join(fields[1:], " ")
+
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, rawDate)
+		if err == nil {
+			return fields[0], date
+		}
+	}
+
+	return fields[0], time.Time{}
+}
+
+// readFlags reads the flags of a message from its status headers
+func readFlags(raw []byte) []string {
+	header, _ := splitHeader(raw)
+	parsedHeader := parseHeader(header)
+
+	status := parsedHeader.Get(statusHeader) + parsedHeader.Get(xStatusHeader)
+	result := make([]string, 0)
+
+	for flag, letter := range statusLetters {
+		if strings.IndexByte(status, letter) != -1 {
+			result = append(result, flag)
+		}
+	}
+
+	if mozillaStatus, err := strconv.ParseUint(parsedHeader.Get(xMozillaStatusField), 16, 16); err == nil {
+		for flag, bit := range mozillaStatusBits {
+			if mozillaStatus&bit != 0 {
+				result = append(result, flag)
+			}
+		}
+	}
+
+	return flags.Normalize(result)
+}
+
+// withStatus replaces the Status and X-Status fields in header by ones describing flagList
+func withStatus(header []byte, flagList []string) []byte {
+	status := "O"
+	xStatus := ""
+
+	for _, flag := range flags.Normalize(flagList) {
+		if flag == flags.Seen {
+			status = "RO"
+		} else {
+			xStatus += string(statusLetters[flag])
+		}
+	}
+
+	buf := bytes.Buffer{}
+	skipping := false
+
+	for _, line := range bytes.SplitAfter(header, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		// Continuation lines belong to the field before them
+		if line[0] != ' ' && line[0] != '\t' {
+			name, _, _ := strings.Cut(string(line), ":")
+			skipping = strings.EqualFold(name, statusHeader) || strings.EqualFold(name, xStatusHeader)
+		}
+
+		if !skipping {
+			buf.Write(line)
+		}
+	}
+
+	buf.WriteString(statusHeader + ": " + status + "\n")
+
+	if xStatus != "" {
+		buf.WriteString(xStatusHeader + ": " + xStatus + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// splitHeader splits a message into its header fields and the rest, starting with the blank line after the header
+func splitHeader(raw []byte) ([]byte, []byte) {
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+
+	index := bytes.Index(raw, []byte("\n\n"))
+	if index == -1 {
+		return raw, nil
+	}
+
+	return raw[:index+1], raw[index+1:]
+}
+
+func parseHeader(header []byte) mail.Header {
+	msg, err := mail.ReadMessage(bytes.NewReader(append(append([]byte{}, header...), '\n')))
+	if err != nil {
+		return mail.Header{}
+	}
+
+	return msg.Header
+}
+
+func headerSender(header mail.Header) string {
+	list, err := header.AddressList("From")
+	if err != nil || len(list) == 0 {
+		return defaultSender
+	}
+
+	return list[0].Address
+}
+
+func headerDate(header mail.Header) time.Time {
+	date, err := header.Date()
+	if err != nil {
+		return time.Unix(0, 0)
+	}
+
+	return date
+}
+
+func isBlank(line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0
+}
+
+func trimSeparatorLine(raw []byte) []byte {
+	switch {
+	case bytes.HasSuffix(raw, []byte("\r\n\r\n")):
+		return raw[:len(raw)-2]
+	case bytes.HasSuffix(raw, []byte("\n\n")):
+		return raw[:len(raw)-1]
+	default:
+		return raw
+	}
+}
@@ -0,0 +1,217 @@
+package mbox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+	"github.com/sebdah/goldie/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	testCases := []struct {
+		name           string
+		withMbox       string
+		expectMessages []Message
+	}{
+		{
+			name: "Should split messages on From lines after blank lines",
+			withMbox: strings.Join([]string{
+				"From alice@example.com Mon Oct  3 10:00:00 2022",
+				"Subject: first",
+				"",
+				"From what I can tell, this is not a separator",
+				"",
+				"From bob@example.com Tue Oct  4 11:30:00 2022",
+				"Subject: second",
+				"",
+				"second body",
+				"",
+			}, "\n"),
+			expectMessages: []Message{
+				{
+					Raw:    []byte("Subject: first\n\nFrom what I can tell, this is not a separator\n"),
+					Sender: "alice@example.com",
+					Date:   time.Date(2022, time.October, 3, 10, 0, 0, 0, time.UTC),
+					Flags:  []string{},
+				},
+				{
+					Raw:    []byte("Subject: second\n\nsecond body\n"),
+					Sender: "bob@example.com",
+					Date:   time.Date(2022, time.October, 4, 11, 30, 0, 0, time.UTC),
+					Flags:  []string{},
+				},
+			},
+		},
+		{
+			name: "Should unescape quoted From lines",
+			withMbox: strings.Join([]string{
+				"From alice@example.com Mon Oct  3 10:00:00 2022",
+				"Subject: quoted",
+				"",
+				">From the start",
+				">>From the quote",
+				"",
+			}, "\n"),
+			expectMessages: []Message{
+				{
+					Raw:    []byte("Subject: quoted\n\nFrom the start\n>From the quote\n"),
+					Sender: "alice@example.com",
+					Date:   time.Date(2022, time.October, 3, 10, 0, 0, 0, time.UTC),
+					Flags:  []string{},
+				},
+			},
+		},
+		{
+			name: "Should read flags from status headers",
+			withMbox: strings.Join([]string{
+				"From alice@example.com Mon Oct  3 10:00:00 2022",
+				"Subject: status",
+				"Status: RO",
+				"X-Status: F",
+				"",
+				"body",
+				"",
+				"From - Tue Oct 4 11:30:00 2022",
+				"Subject: mozilla",
+				"X-Mozilla-Status: 0003",
+				"",
+				"body",
+			}, "\n"),
+			expectMessages: []Message{
+				{
+					Raw:    []byte("Subject: status\nStatus: RO\nX-Status: F\n\nbody\n"),
+					Sender: "alice@example.com",
+					Date:   time.Date(2022, time.October, 3, 10, 0, 0, 0, time.UTC),
+					Flags:  []string{flags.Seen, flags.Flagged},
+				},
+				{
+					Raw:    []byte("Subject: mozilla\nX-Mozilla-Status: 0003\n\nbody"),
+					Sender: "-",
+					Date:   time.Date(2022, time.October, 4, 11, 30, 0, 0, time.UTC),
+					Flags:  []string{flags.Seen, flags.Answered},
+				},
+			},
+		},
+		{
+			name:           "Should read nothing from empty files",
+			withMbox:       "",
+			expectMessages: []Message{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := NewReader(strings.NewReader(tc.withMbox))
+			messages := make([]Message, 0)
+
+			for {
+				msg, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				assert.NoError(t, err)
+
+				messages = append(messages, msg)
+			}
+
+			assert.Len(t, messages, len(tc.expectMessages))
+
+			for index, expected := range tc.expectMessages {
+				assert.Equal(t, string(expected.Raw), string(messages[index].Raw))
+				assert.Equal(t, expected.Sender, messages[index].Sender)
+				assert.Equal(t, expected.Date, messages[index].Date)
+				assert.ElementsMatch(t, expected.Flags, messages[index].Flags)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withMessages []Message
+	}{
+		{
+			name: "Should write From lines from the header when missing",
+			withMessages: []Message{
+				{
+					Raw: []byte(strings.Join([]string{
+						"From: Alice <alice@example.com>",
+						"Date: Mon, 03 Oct 2022 12:00:00 +0200",
+						"Subject: mock",
+						"",
+						"mock body",
+						"",
+					}, "\r\n")),
+				},
+			},
+		},
+		{
+			name: "Should escape From lines and replace status headers",
+			withMessages: []Message{
+				{
+					Raw: []byte(strings.Join([]string{
+						"Subject: escaped",
+						"Status: O",
+						"X-Status: A",
+						"",
+						"From the start",
+						">From the quote",
+					}, "\n")),
+					Sender: "bob@example.com",
+					Date:   time.Date(2022, time.October, 4, 11, 30, 0, 0, time.UTC),
+					Flags:  []string{flags.Seen, flags.Flagged},
+				},
+				{
+					Raw:    []byte("Subject: second\n\nsecond body\n"),
+					Sender: "bob@example.com",
+					Date:   time.Date(2022, time.October, 5, 9, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.Buffer{}
+			writer := NewWriter(&buf)
+
+			for _, msg := range tc.withMessages {
+				err := writer.Write(msg)
+				assert.NoError(t, err)
+			}
+
+			g := goldie.New(t)
+			g.Assert(t, t.Name(), buf.Bytes())
+
+			// Everything written should be read back the same way
+			reader := NewReader(&buf)
+
+			for _, msg := range tc.withMessages {
+				read, err := reader.Next()
+				assert.NoError(t, err)
+
+				assert.ElementsMatch(t, flags.Normalize(msg.Flags), read.Flags)
+				assert.Contains(t, strings.ReplaceAll(string(read.Raw), "\n", "\r\n"), "\r\n\r\n")
+			}
+
+			_, err := reader.Next()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
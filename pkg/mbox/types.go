@@ -0,0 +1,66 @@
+package mbox
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"github.com/deifyed/fsmail/pkg/flags"
+)
+
+// Message is a single message in an mbox file
+type Message struct {
+	// Raw contains the RFC 5322 message, without the From line separating it from other messages
+	Raw []byte
+	// Sender and Date are written in the From line. When empty, they are taken from the From and Date headers
+	Sender string
+	Date   time.Time
+	// Flags contains the flags recorded in the Status, X-Status and X-Mozilla-Status headers
+	Flags []string
+}
+
+// Reader knows how to split an mbox file into messages
+type Reader struct {
+	r *bufio.Reader
+	// separator contains the From line starting the next message, once it has been read
+	separator []byte
+}
+
+// Writer knows how to append messages to an mbox file
+type Writer struct {
+	w io.Writer
+}
+
+const (
+	separatorPrefix = "From "
+	// dateLayout is the layout of the date in From lines, as written by asctime
+	dateLayout = "Mon Jan _2 15:04:05 2006"
+	// defaultSender is written in From lines of messages without a sender
+	defaultSender = "MAILER-DAEMON"
+
+	statusHeader        = "Status"
+	xStatusHeader       = "X-Status"
+	xMozillaStatusField = "X-Mozilla-Status"
+)
+
+// dateLayouts contains the layouts of dates in From lines written by common mail programs
+var dateLayouts = []string{
+	dateLayout,
+	"Mon Jan _2 15:04:05 -0700 2006",
+	"Mon Jan _2 15:04:05 2006 -0700",
+	"Mon Jan _2 15:04 2006",
+}
+
+// statusLetters maps the flags to their letters in the Status and X-Status headers
+var statusLetters = map[string]byte{
+	flags.Seen:     'R',
+	flags.Answered: 'A',
+	flags.Flagged:  'F',
+}
+
+// mozillaStatusBits maps the flags to their bits in the X-Mozilla-Status header written by Thunderbird
+var mozillaStatusBits = map[string]uint64{
+	flags.Seen:     0x0001,
+	flags.Answered: 0x0002,
+	flags.Flagged:  0x0004,
+}
@@ -0,0 +1,48 @@
+package messageid
+
+import (
+	"strings"
+)
+
+// ParseList knows how to extract the message IDs from a header value such as `<a@example.com> <b@example.com>`. The
+// IDs are returned without angle brackets
+func ParseList(raw string) []string {
+	result := make([]string, 0)
+
+	for _, item := range strings.FieldsFunc(raw, isSeparator) {
+		item = strings.TrimSuffix(strings.TrimPrefix(item, "<"), ">")
+
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// Parse knows how to extract a single message ID from a header value. Returns an empty string when there is none
+func Parse(raw string) string {
+	list := ParseList(raw)
+	if len(list) == 0 {
+		return ""
+	}
+
+	return list[0]
+}
+
+// Format knows how to turn message IDs into a header value with every ID in angle brackets
+func Format(ids ...string) string {
+	result := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		if id != "" {
+			result = append(result, "<"+id+">")
+		}
+	}
+
+	return strings.Join(result, " ")
+}
+
+func isSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
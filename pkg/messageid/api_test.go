@@ -0,0 +1,75 @@
+package messageid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	testCases := []struct {
+		name       string
+		withRaw    string
+		expectList []string
+	}{
+		{
+			name:       "Should parse a single message ID",
+			withRaw:    "<abc@example.com>",
+			expectList: []string{"abc@example.com"},
+		},
+		{
+			name:       "Should parse a list of message IDs",
+			withRaw:    "<abc@example.com> <def@example.com>,\t<ghi@example.com>",
+			expectList: []string{"abc@example.com", "def@example.com", "ghi@example.com"},
+		},
+		{
+			name:       "Should accept message IDs without angle brackets",
+			withRaw:    "abc@example.com",
+			expectList: []string{"abc@example.com"},
+		},
+		{
+			name:       "Should return an empty list for empty input",
+			withRaw:    " ",
+			expectList: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectList, ParseList(tc.withRaw))
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withIDs      []string
+		expectResult string
+	}{
+		{
+			name:         "Should wrap message IDs in angle brackets",
+			withIDs:      []string{"abc@example.com", "def@example.com"},
+			expectResult: "<abc@example.com> <def@example.com>",
+		},
+		{
+			name:         "Should skip empty message IDs",
+			withIDs:      []string{"", "abc@example.com"},
+			expectResult: "<abc@example.com>",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectResult, Format(tc.withIDs...))
+		})
+	}
+}
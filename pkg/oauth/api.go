@@ -0,0 +1,278 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Enabled knows if the account authenticates with OAuth 2.0 instead of a password
+func (c Config) Enabled() bool {
+	return c.ClientID != "" || c.Provider != ""
+}
+
+// Resolve fills in the settings of the provider and the defaults, and validates the result
+func (c Config) Resolve() (Config, error) {
+	if c.Provider != "" {
+		preset, ok := providers[strings.ToLower(c.Provider)]
+		if !ok {
+			return Config{}, fmt.Errorf("%w %q, expected %s or %s",
+				errUnknownProvider, c.Provider, ProviderGoogle, ProviderMicrosoft)
+		}
+
+		c.AuthURL = withDefault(c.AuthURL, preset.AuthURL)
+		c.TokenURL = withDefault(c.TokenURL, preset.TokenURL)
+		c.DeviceAuthURL = withDefault(c.DeviceAuthURL, preset.DeviceAuthURL)
+
+		if len(c.Scopes) == 0 {
+			c.Scopes = preset.Scopes
+		}
+	}
+
+	c.Flow = withDefault(strings.ToLower(c.Flow), FlowLoopback)
+	c.Mechanism = withDefault(strings.ToUpper(c.Mechanism), MechanismXOAUTH2)
+
+	switch {
+	case c.ClientID == "":
+		return Config{}, fmt.Errorf("clientID is required: %w", errInvalidConfig)
+	case c.TokenURL == "":
+		return Config{}, fmt.Errorf("tokenURL is required: %w", errInvalidConfig)
+	case c.Flow == FlowLoopback && c.AuthURL == "":
+		return Config{}, fmt.Errorf("authURL is required by the %s flow: %w", FlowLoopback, errInvalidConfig)
+	case c.Flow == FlowDevice && c.DeviceAuthURL == "":
+		return Config{}, fmt.Errorf("deviceAuthURL is required by the %s flow: %w", FlowDevice, errInvalidConfig)
+	case c.Flow != FlowLoopback && c.Flow != FlowDevice:
+		return Config{}, fmt.Errorf("%w %q, expected %s or %s", errUnknownFlow, c.Flow, FlowLoopback, FlowDevice)
+	case c.Mechanism != MechanismXOAUTH2 && c.Mechanism != MechanismOAUTHBEARER:
+		return Config{}, fmt.Errorf("%w %q, expected %s or %s",
+			errUnknownMechanism, c.Mechanism, MechanismXOAUTH2, MechanismOAUTHBEARER)
+	}
+
+	return c, nil
+}
+
+// Authorize knows how to let the user authorize fsmail, printing instructions to out. It returns a token containing a
+// refresh token
+func Authorize(ctx context.Context, cfg Config, out io.Writer) (*oauth2.Token, error) {
+	cfg, err := cfg.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authorizationTimeout)
+	defer cancel()
+
+	var token *oauth2.Token
+
+	switch cfg.Flow {
+	case FlowDevice:
+		token, err = authorizeDevice(ctx, cfg, out)
+	default:
+		token, err = authorizeLoopback(ctx, cfg, out)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("the provider returned no refresh token, check the scopes: %w", errMissingRefresh)
+	}
+
+	return token, nil
+}
+
+// TokenSource knows how to provide access tokens from a refresh token. Access tokens are reused until they expire, and
+// refreshed automatically afterwards. Providers might replace the refresh token when refreshing, and the old one stops
+// working eventually. rotated is called with the new refresh token every time that happens, unless it is nil
+func TokenSource(ctx context.Context, cfg Config, refreshToken string, rotated func(string) error) (func() (string, error), error) {
+	cfg, err := cfg.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken == "" {
+		return nil, fmt.Errorf("log in again: %w", errMissingRefresh)
+	}
+
+	source := oauth2.ReuseTokenSource(nil, toOAuth2Config(cfg, "").TokenSource(ctx, &oauth2.Token{
+		RefreshToken: refreshToken,
+	}))
+
+	// Access tokens are requested from both the IMAP and SMTP connections
+	var lock sync.Mutex
+
+	return func() (string, error) {
+		token, err := source.Token()
+		if err != nil {
+			return "", fmt.Errorf("refreshing access token: %w", err)
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if token.RefreshToken != "" && token.RefreshToken != refreshToken && rotated != nil {
+			err = rotated(token.RefreshToken)
+			if err != nil {
+				return "", fmt.Errorf("storing the new refresh token: %w", err)
+			}
+
+			refreshToken = token.RefreshToken
+		}
+
+		return token.AccessToken, nil
+	}, nil
+}
+
+func authorizeDevice(ctx context.Context, cfg Config, out io.Writer) (*oauth2.Token, error) {
+	conf := toOAuth2Config(cfg, "")
+
+	response, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("requesting device code: %w", err)
+	}
+
+	verificationURI := response.VerificationURIComplete
+	if verificationURI == "" {
+		verificationURI = response.VerificationURI
+	}
+
+	fmt.Fprintf(out, "Open %s and enter the code %s\n", verificationURI, response.UserCode)
+
+	token, err := conf.DeviceAccessToken(ctx, response)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for authorization: %w", err)
+	}
+
+	return token, nil
+}
+
+// authorizeLoopback runs a server on the loopback interface, which the authorization page redirects to with a code.
+// PKCE and a random state make sure the code is only usable by this process. Consent is asked for every time, as
+// providers such as Google only return a refresh token the first time fsmail is authorized
+func authorizeLoopback(ctx context.Context, cfg Config, out io.Writer) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening for the redirect: %w", err)
+	}
+
+	conf := toOAuth2Config(cfg, fmt.Sprintf("http://%s%s", listener.Addr().String(), callbackPath))
+
+	state, err := randomState()
+	if err != nil {
+		return nil, fmt.Errorf("generating state: %w", err)
+	}
+
+	verifier := oauth2.GenerateVerifier()
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+
+	server := &http.Server{
+		Handler:           callbackHandler(state, codes, failures),
+		ReadHeaderTimeout: authorizationTimeout,
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	defer func() {
+		_ = server.Close()
+	}()
+
+	fmt.Fprintf(out, "Open this address in your browser to authorize fsmail:\n\n%s\n\n",
+		conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce, oauth2.S256ChallengeOption(verifier)))
+
+	select {
+	case code := <-codes:
+		token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+		if err != nil {
+			return nil, fmt.Errorf("exchanging code: %w", err)
+		}
+
+		return token, nil
+	case err := <-failures:
+		return nil, err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for authorization: %w", ctx.Err())
+	}
+}
+
+// callbackHandler receives the redirect from the authorization page. Requests with another state are refused without
+// ending the flow, as they don't come from the page fsmail printed
+func callbackHandler(state string, codes chan<- string, failures chan<- error) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if query.Get("state") != state {
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+
+			return
+		}
+
+		if query.Get("error") != "" || query.Get("code") == "" {
+			err := fmt.Errorf("%s %s: %w", query.Get("error"), query.Get("error_description"), errAuthorization)
+
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			select {
+			case failures <- err:
+			default:
+			}
+
+			return
+		}
+
+		fmt.Fprintln(w, "fsmail is authorized. You can close this window.")
+
+		select {
+		case codes <- query.Get("code"):
+		default:
+		}
+	})
+
+	return mux
+}
+
+func toOAuth2Config(cfg Config, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:       cfg.AuthURL,
+			TokenURL:      cfg.TokenURL,
+			DeviceAuthURL: cfg.DeviceAuthURL,
+		},
+		RedirectURL: redirectURL,
+		Scopes:      cfg.Scopes,
+	}
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func withDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
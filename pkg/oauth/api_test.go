@@ -0,0 +1,270 @@
+package oauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeProvider starts a token endpoint handing out numbered access tokens, and a device endpoint
+func newFakeProvider(t *testing.T) (*httptest.Server, *int32) {
+	var issued int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			assert.Equal(t, "mock-code", r.Form.Get("code"))
+			assert.NotEmpty(t, r.Form.Get("code_verifier"))
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			assert.Equal(t, "mock-device-code", r.Form.Get("device_code"))
+		case "refresh_token":
+			// The replaced refresh token still works once, like with providers rotating refresh tokens
+			refreshToken := r.Form.Get("refresh_token")
+			if refreshToken != "mock-refresh-token" && refreshToken != "mock-replaced-refresh-token" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, `{"error": "invalid_grant"}`)
+
+				return
+			}
+		}
+
+		count := atomic.AddInt32(&issued, 1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "mock-access-token-" + string(rune('0'+count)),
+			"refresh_token": "mock-refresh-token",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":      "mock-device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://example.com/device",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &issued
+}
+
+func TestTokenSource(t *testing.T) {
+	provider, issued := newFakeProvider(t)
+
+	cfg := Config{ClientID: "mock-client", AuthURL: provider.URL + "/auth", TokenURL: provider.URL + "/token"}
+
+	token, err := TokenSource(context.Background(), cfg, "mock-refresh-token", nil)
+	assert.NoError(t, err)
+
+	accessToken, err := token()
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-access-token-1", accessToken)
+
+	// The access token is reused until it expires
+	accessToken, err = token()
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-access-token-1", accessToken)
+	assert.Equal(t, int32(1), atomic.LoadInt32(issued))
+
+	revoked, err := TokenSource(context.Background(), cfg, "revoked-refresh-token", nil)
+	assert.NoError(t, err)
+
+	_, err = revoked()
+	assert.ErrorContains(t, err, "invalid_grant")
+
+	_, err = TokenSource(context.Background(), cfg, "", nil)
+	assert.ErrorIs(t, err, errMissingRefresh)
+}
+
+func TestTokenSourceRotation(t *testing.T) {
+	provider, _ := newFakeProvider(t)
+
+	cfg := Config{ClientID: "mock-client", AuthURL: provider.URL + "/auth", TokenURL: provider.URL + "/token"}
+
+	rotations := make([]string, 0)
+	rotated := func(refreshToken string) error {
+		rotations = append(rotations, refreshToken)
+
+		return nil
+	}
+
+	token, err := TokenSource(context.Background(), cfg, "mock-replaced-refresh-token", rotated)
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = token()
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"mock-refresh-token"}, rotations)
+
+	// Refresh tokens that stay the same are not stored again
+	token, err = TokenSource(context.Background(), cfg, "mock-refresh-token", rotated)
+	assert.NoError(t, err)
+
+	_, err = token()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"mock-refresh-token"}, rotations)
+}
+
+func TestAuthorizeLoopback(t *testing.T) {
+	provider, _ := newFakeProvider(t)
+
+	cfg := Config{ClientID: "mock-client", AuthURL: provider.URL + "/auth", TokenURL: provider.URL + "/token"}
+
+	reader, writer := io.Pipe()
+	tokens := make(chan string, 1)
+
+	go func() {
+		token, err := Authorize(context.Background(), cfg, writer)
+		assert.NoError(t, err)
+
+		if token != nil {
+			tokens <- token.RefreshToken
+		}
+
+		close(tokens)
+	}()
+
+	// Play the browser: follow the printed address to the provider, which redirects back with a code
+	scanner := bufio.NewScanner(reader)
+
+	var authURL *url.URL
+
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), provider.URL) {
+			var err error
+
+			authURL, err = url.Parse(scanner.Text())
+			assert.NoError(t, err)
+
+			break
+		}
+	}
+
+	go func() {
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+
+	query := authURL.Query()
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, "offline", query.Get("access_type"))
+	assert.Equal(t, "consent", query.Get("prompt"))
+
+	response, err := http.Get(query.Get("redirect_uri") + "?state=wrong&code=mock-code")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	_ = response.Body.Close()
+
+	response, err = http.Get(query.Get("redirect_uri") + "?state=" + query.Get("state") + "&code=mock-code")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	_ = response.Body.Close()
+
+	assert.Equal(t, "mock-refresh-token", <-tokens)
+}
+
+func TestAuthorizeDevice(t *testing.T) {
+	provider, _ := newFakeProvider(t)
+
+	cfg := Config{
+		ClientID:      "mock-client",
+		TokenURL:      provider.URL + "/token",
+		DeviceAuthURL: provider.URL + "/device",
+		Flow:          FlowDevice,
+	}
+
+	out := &strings.Builder{}
+
+	token, err := Authorize(context.Background(), cfg, out)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "mock-refresh-token", token.RefreshToken)
+	assert.Equal(t, "Open https://example.com/device and enter the code ABCD-EFGH\n", out.String())
+}
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name         string
+		withConfig   Config
+		expectConfig Config
+		expectErr    error
+	}{
+		{
+			name:       "Should fill in well known providers",
+			withConfig: Config{Provider: "Google", ClientID: "mock-client"},
+			expectConfig: Config{
+				Provider:      "Google",
+				ClientID:      "mock-client",
+				AuthURL:       "https://accounts.google.com/o/oauth2/auth",
+				TokenURL:      "https://oauth2.googleapis.com/token",
+				DeviceAuthURL: "https://oauth2.googleapis.com/device/code",
+				Scopes:        []string{"https://mail.google.com/"},
+				Flow:          FlowLoopback,
+				Mechanism:     MechanismXOAUTH2,
+			},
+		},
+		{
+			name:       "Should require a client ID",
+			withConfig: Config{Provider: ProviderMicrosoft},
+			expectErr:  errInvalidConfig,
+		},
+		{
+			name:       "Should require a device endpoint for the device flow",
+			withConfig: Config{ClientID: "mock-client", TokenURL: "https://example.com/token", Flow: FlowDevice},
+			expectErr:  errInvalidConfig,
+		},
+		{
+			name:       "Should fail on unknown providers",
+			withConfig: Config{Provider: "example", ClientID: "mock-client"},
+			expectErr:  errUnknownProvider,
+		},
+		{
+			name:       "Should fail on unknown mechanisms",
+			withConfig: Config{Provider: ProviderGoogle, ClientID: "mock-client", Mechanism: "plain"},
+			expectErr:  errUnknownMechanism,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := tc.withConfig.Resolve()
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectConfig, cfg)
+		})
+	}
+}
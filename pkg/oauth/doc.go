@@ -0,0 +1,2 @@
+// Package oauth knows how to authorize fsmail with OAuth 2.0 and keep access tokens fresh
+package oauth
@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"errors"
+	"time"
+)
+
+// Config describes the OAuth 2.0 client fsmail authorizes as, and the endpoints of the provider
+type Config struct {
+	// Provider fills in the endpoints and scopes of a well known provider. One of ProviderGoogle or ProviderMicrosoft
+	Provider     string `mapstructure:"provider"`
+	ClientID     string `mapstructure:"clientID"`
+	ClientSecret string `mapstructure:"clientSecret"`
+	AuthURL      string `mapstructure:"authURL"`
+	TokenURL     string `mapstructure:"tokenURL"`
+	// DeviceAuthURL is required by FlowDevice
+	DeviceAuthURL string   `mapstructure:"deviceAuthURL"`
+	Scopes        []string `mapstructure:"scopes"`
+	// Flow decides how the user authorizes fsmail. One of FlowLoopback or FlowDevice
+	Flow string `mapstructure:"flow"`
+	// Mechanism is the SASL mechanism authenticating with access tokens. One of MechanismXOAUTH2 or
+	// MechanismOAUTHBEARER
+	Mechanism string `mapstructure:"mechanism"`
+}
+
+// Names of the well known providers
+const (
+	ProviderGoogle    = "google"
+	ProviderMicrosoft = "microsoft"
+)
+
+// Names of the authorization flows
+const (
+	// FlowLoopback opens the authorization page in a browser, which redirects back to a server fsmail runs locally
+	FlowLoopback = "loopback"
+	// FlowDevice shows a code to enter on another device, for machines without a browser
+	FlowDevice = "device"
+)
+
+// Names of the SASL mechanisms
+const (
+	MechanismXOAUTH2     = "XOAUTH2"
+	MechanismOAUTHBEARER = "OAUTHBEARER"
+)
+
+// providers contains the endpoints and scopes of the well known providers
+var providers = map[string]Config{
+	ProviderGoogle: {
+		AuthURL:       "https://accounts.google.com/o/oauth2/auth",
+		TokenURL:      "https://oauth2.googleapis.com/token",
+		DeviceAuthURL: "https://oauth2.googleapis.com/device/code",
+		Scopes:        []string{"https://mail.google.com/"},
+	},
+	ProviderMicrosoft: {
+		AuthURL:       "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+		TokenURL:      "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+		DeviceAuthURL: "https://login.microsoftonline.com/common/oauth2/v2.0/devicecode",
+		Scopes: []string{
+			"https://outlook.office.com/IMAP.AccessAsUser.All",
+			"https://outlook.office.com/SMTP.Send",
+			"offline_access",
+		},
+	},
+}
+
+// authorizationTimeout defines how long the user has to authorize fsmail
+const authorizationTimeout = 5 * time.Minute
+
+// callbackPath is where the authorization page redirects to in the loopback flow
+const callbackPath = "/callback"
+
+var (
+	errInvalidConfig    = errors.New("invalid OAuth 2.0 configuration")
+	errAuthorization    = errors.New("authorization failed")
+	errMissingRefresh   = errors.New("missing refresh token")
+	errUnknownProvider  = errors.New("unknown provider")
+	errUnknownFlow      = errors.New("unknown flow")
+	errUnknownMechanism = errors.New("unknown mechanism")
+)
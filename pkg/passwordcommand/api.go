@@ -0,0 +1,58 @@
+package passwordcommand
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+)
+
+// Put fails, as the password is managed by the program behind the command
+func (c Client) Put(string, map[string]string) error {
+	return fmt.Errorf("store the password where %q reads it from: %w", c.Command, credentials.ErrReadOnly)
+}
+
+// Delete fails, as the password is managed by the program behind the command
+func (c Client) Delete(string) error {
+	return fmt.Errorf("remove the password where %q reads it from: %w", c.Command, credentials.ErrReadOnly)
+}
+
+// Get knows how to retrieve the username and the password. Server addresses are configured, so nothing is returned for
+// them
+func (c Client) Get(_ string, key string) (string, error) {
+	switch key {
+	case credentials.UsernameKey:
+		if c.Username == "" {
+			return "", fmt.Errorf("configure a username to use with the password command: %w", errMissingUsername)
+		}
+
+		return c.Username, nil
+	case credentials.PasswordKey:
+		return c.password()
+	default:
+		return "", nil
+	}
+}
+
+func (c Client) password() (string, error) {
+	// #nosec G204 running a configured command is the point
+	cmd := exec.Command("sh", "-c", c.Command)
+	// Password managers might prompt for a passphrase or print hints on stderr
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running %q: %w", c.Command, err)
+	}
+
+	line, _, _ := bufio.NewReader(bytes.NewReader(output)).ReadLine()
+	if len(line) == 0 {
+		return "", fmt.Errorf("running %q: %w", c.Command, errEmptyPassword)
+	}
+
+	return string(line), nil
+}
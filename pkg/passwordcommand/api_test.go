@@ -0,0 +1,74 @@
+package passwordcommand
+
+import (
+	"testing"
+
+	"github.com/deifyed/fsmail/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withClient  Client
+		withKey     string
+		expectValue string
+		expectErr   error
+	}{
+		{
+			name:        "Should return the first line printed by the command",
+			withClient:  Client{Command: `printf 'secret\nurl: example.com\n'`},
+			withKey:     credentials.PasswordKey,
+			expectValue: "secret",
+		},
+		{
+			name:       "Should fail when the command prints nothing",
+			withClient: Client{Command: "true"},
+			withKey:    credentials.PasswordKey,
+			expectErr:  errEmptyPassword,
+		},
+		{
+			name:        "Should return the configured username",
+			withClient:  Client{Command: "false", Username: "jane@example.com"},
+			withKey:     credentials.UsernameKey,
+			expectValue: "jane@example.com",
+		},
+		{
+			name:       "Should fail without a configured username",
+			withClient: Client{Command: "false"},
+			withKey:    credentials.UsernameKey,
+			expectErr:  errMissingUsername,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := tc.withClient.Get(credentials.CredentialsSecretName, tc.withKey)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectValue, value)
+		})
+	}
+}
+
+func TestPut(t *testing.T) {
+	err := Client{Command: "pass show mail"}.Put(credentials.CredentialsSecretName, map[string]string{})
+
+	assert.ErrorIs(t, err, credentials.ErrReadOnly)
+}
+
+func TestDelete(t *testing.T) {
+	err := Client{Command: "pass show mail"}.Delete(credentials.CredentialsSecretName)
+
+	assert.ErrorIs(t, err, credentials.ErrReadOnly)
+}
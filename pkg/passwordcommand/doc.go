@@ -0,0 +1,2 @@
+// Package passwordcommand exposes credentials where the password is printed by a command, such as a password manager
+package passwordcommand
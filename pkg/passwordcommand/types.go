@@ -0,0 +1,15 @@
+package passwordcommand
+
+import "errors"
+
+// Client exposes the password printed by a command together with a configured username
+type Client struct {
+	// Command is run by the shell, e.g. `pass show mail/work`. The first line of its output is the password
+	Command  string
+	Username string
+}
+
+var (
+	errMissingUsername = errors.New("missing username")
+	errEmptyPassword   = errors.New("empty password")
+)
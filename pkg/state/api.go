@@ -0,0 +1,55 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/afero"
+)
+
+// Load knows how to read the state file at path. A missing file results in an empty state
+func Load(fs *afero.Afero, path string) (State, error) {
+	s := State{Mailboxes: make(map[string]Mailbox)}
+
+	exists, err := fs.Exists(path)
+	if err != nil {
+		return State{}, fmt.Errorf("checking existence: %w", err)
+	}
+
+	if !exists {
+		return s, nil
+	}
+
+	raw, err := fs.ReadFile(path)
+	if err != nil {
+		return State{}, fmt.Errorf("reading: %w", err)
+	}
+
+	err = json.Unmarshal(raw, &s)
+	if err != nil {
+		return State{}, fmt.Errorf("unmarshalling: %w", err)
+	}
+
+	if s.Mailboxes == nil {
+		s.Mailboxes = make(map[string]Mailbox)
+	}
+
+	return s, nil
+}
+
+// Save knows how to write the state to the state file at path
+func Save(fs *afero.Afero, path string, s State) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling: %w", err)
+	}
+
+	err = fs.WriteFile(path, raw, defaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+
+	return nil
+}
+
+const defaultFilePermissions = 0o600
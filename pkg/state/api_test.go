@@ -0,0 +1,66 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name        string
+		withContent string
+		expectState State
+	}{
+		{
+			name:        "Should return an empty state when the file is missing",
+			expectState: State{Mailboxes: map[string]Mailbox{}},
+		},
+		{
+			name:        "Should read an existing state",
+			withContent: `{"mailboxes":{"INBOX":{"uidValidity":3,"lastUID":42}}}`,
+			expectState: State{Mailboxes: map[string]Mailbox{
+				"INBOX": {UIDValidity: 3, LastUID: 42},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+			statePath := "/work/" + Filename
+
+			if tc.withContent != "" {
+				err := fs.WriteFile(statePath, []byte(tc.withContent), 0o600)
+				assert.NoError(t, err)
+			}
+
+			s, err := Load(fs, statePath)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectState, s)
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	fs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	statePath := "/work/" + Filename
+
+	original := State{Mailboxes: map[string]Mailbox{
+		"INBOX": {UIDValidity: 1, LastUID: 7},
+	}}
+
+	err := Save(fs, statePath, original)
+	assert.NoError(t, err)
+
+	loaded, err := Load(fs, statePath)
+	assert.NoError(t, err)
+
+	assert.Equal(t, original, loaded)
+}
@@ -0,0 +1,34 @@
+package state
+
+// Filename defines the name of the state file kept in the work directory
+const Filename = ".fsmail-state.json"
+
+// State describes what has already been synchronized with the server
+type State struct {
+	// Storage is the name of the storage backend the messages were stored with. Empty for message files
+	Storage   string             `json:"storage,omitempty"`
+	Mailboxes map[string]Mailbox `json:"mailboxes"`
+}
+
+// Mailbox describes the synchronization progress of a single IMAP mailbox
+type Mailbox struct {
+	// Directory is the location of the mailbox directory relative to the work directory
+	Directory string `json:"directory,omitempty"`
+	// UIDValidity is the UIDVALIDITY value the server reported during the last sync
+	UIDValidity uint32 `json:"uidValidity"`
+	// LastUID is the highest UID that has been downloaded
+	LastUID uint32 `json:"lastUID"`
+	// Messages maps the UID of every downloaded message to its local representation
+	Messages map[uint32]Message `json:"messages,omitempty"`
+}
+
+// Message describes a downloaded message as it was after the last sync
+type Message struct {
+	// Path is the location of the message file relative to the mailbox directory
+	Path string `json:"path"`
+	// Flags are the flags of the message after the last sync
+	Flags []string `json:"flags"`
+	// MessageID identifies the message when its file has been renamed or moved. Empty for messages downloaded before it
+	// was recorded
+	MessageID string `json:"messageID,omitempty"`
+}